@@ -0,0 +1,79 @@
+package snug
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// constraint restricts the values a url parameter accepts.
+//
+// Named constraints are int and uuid, any other expression is compiled
+// as a regular expression that must match the whole segment.
+type constraint struct {
+	expr   string
+	accept func(string) bool
+}
+
+// Create constraint from expression, empty expression returns nil.
+func newConstraint(expr string) (*constraint, error) {
+	switch expr {
+	case "":
+		return nil, nil
+	case "int":
+		return &constraint{expr, isInt}, nil
+	case "uuid":
+		return &constraint{expr, isUUID}, nil
+	}
+	re, err := compileWhole(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &constraint{expr, re.MatchString}, nil
+}
+
+// Compile regular expression matching the whole value. Expression is compiled
+// on its own first, so that errors refer to what user wrote.
+func compileWhole(expr string) (*regexp.Regexp, error) {
+	if _, err := regexp.Compile(expr); err != nil {
+		return nil, err
+	}
+	return regexp.Compile("^(?:" + expr + ")$")
+}
+
+// Return expression of constraint, empty for nil.
+func (c *constraint) source() string {
+	if c == nil {
+		return ""
+	}
+	return c.expr
+}
+
+// Report whether value satisfies constraint, nil accepts anything.
+func (c *constraint) match(value string) bool {
+	return c == nil || c.accept(value)
+}
+
+func isInt(s string) bool {
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
+
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
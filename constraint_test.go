@@ -0,0 +1,39 @@
+package snug
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestConstraint(t *testing.T) {
+	its := is.New(t)
+
+	testcases := []struct {
+		expr     string
+		value    string
+		expected bool
+	}{
+		{"", "anything", true},
+		{"int", "42", true},
+		{"int", "-42", true},
+		{"int", "4a", false},
+		{"int", "99999999999999999999", false},
+		{"uuid", "123e4567-e89b-12d3-a456-426614174000", true},
+		{"uuid", "123E4567-E89B-12D3-A456-426614174000", true},
+		{"uuid", "123e4567e89b12d3a456426614174000", false},
+		{"uuid", "123e4567-e89b-12d3-a456-42661417400g", false},
+		{"[a-z-]+", "hello-world", true},
+		{"[a-z-]+", "hello_world", false},
+		{"a|b", "ab", false},
+	}
+
+	for _, tc := range testcases {
+		c, err := newConstraint(tc.expr)
+		its.NoErr(err)
+		its.Equal(c.match(tc.value), tc.expected) // constraint result != expected
+	}
+
+	_, err := newConstraint("[a-z")
+	its.True(err != nil) // invalid regular expression accepted
+}
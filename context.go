@@ -0,0 +1,68 @@
+package snug
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+)
+
+type contextVar string
+
+// routeContext holds values resolved by router for a request.
+type routeContext struct {
+	logger Logger
+	// matched route, nil when a fallback handler is called
+	route  *Route
+	params map[string]string
+	// methods allowed for path, set when calling MethodNotAllowed and Options
+	allow []string
+	// set by AssignRequestID, which may run on a goroutine of Timeout
+	requestID atomic.Value
+	// route handler or fallback called by dispatch
+	handler http.HandlerFunc
+}
+
+// Return request ID assigned by AssignRequestID, empty if not assigned.
+func (rc *routeContext) loadRequestID() string {
+	id, _ := rc.requestID.Load().(string)
+	return id
+}
+
+// Return route context set by router, nil if request was not routed by snug.
+func contextOf(r *http.Request) *routeContext {
+	rc, _ := r.Context().Value(contextVar("route")).(*routeContext)
+	return rc
+}
+
+// Add route context to request.
+func withRouteContext(r *http.Request, rc *routeContext) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), contextVar("route"), rc))
+}
+
+// Pull pattern of the route matched for request, for example /greet/age/<name>.
+// Pattern includes router prefix. Returns an empty string when no route
+// matched, like in NotFound.
+//
+//	func Metrics(next http.HandlerFunc) http.HandlerFunc {
+//		return func(w http.ResponseWriter, r *http.Request) {
+//			next(w, r)
+//			count(snug.Pattern(r))
+//		}
+//	}
+func Pattern(r *http.Request) string {
+	rc := contextOf(r)
+	if rc == nil || rc.route == nil {
+		return ""
+	}
+	return rc.route.pattern
+}
+
+// Pull the route matched for request. Reports false when no route matched,
+// like in NotFound.
+func MatchedRoute(r *http.Request) (RouteInfo, bool) {
+	rc := contextOf(r)
+	if rc == nil || rc.route == nil {
+		return RouteInfo{}, false
+	}
+	return rc.route.info(), true
+}
@@ -0,0 +1,190 @@
+package snug
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CORSOptions configures CORS middleware.
+type CORSOptions struct {
+	// AllowedOrigins lists origins allowed to make cross-origin requests.
+	// "*" allows any origin. An origin can contain one wildcard to match
+	// subdomains, like "https://*.example.com".
+	AllowedOrigins []string
+	// AllowOriginFunc is consulted for origins not listed in AllowedOrigins.
+	AllowOriginFunc func(origin string) bool
+	// AllowedMethods limits methods announced in preflight responses.
+	// By default all methods registered for the requested path are allowed.
+	// For paths no route matches, only these methods are allowed.
+	AllowedMethods []string
+	// AllowedHeaders lists request headers clients can use, "*" allows any.
+	// By default headers requested in preflight are allowed.
+	AllowedHeaders []string
+	// ExposedHeaders lists response headers readable by clients.
+	ExposedHeaders []string
+	// AllowCredentials lets clients send cookies and authorization headers.
+	AllowCredentials bool
+	// MaxAge is seconds a preflight response can be cached, 0 omits the header.
+	MaxAge int
+}
+
+// CORS returns a middleware answering preflight requests and adding CORS
+// headers to responses for allowed origins.
+//
+// Preflight requests are answered with methods actually registered for the
+// requested path, so add CORS with UseMiddleware to have it wrap the router's
+// Options handler. This holds also for paths with an explicit OPTIONS route.
+// A route registered for any method, like one added by Mount, accepts the
+// requested method. Requests from origins that are not allowed are passed on
+// without CORS headers, which makes browsers block them.
+//
+//	r := snug.New()
+//	r.UseMiddleware(snug.CORS(snug.CORSOptions{
+//		AllowedOrigins:   []string{"https://example.com", "https://*.example.com"},
+//		AllowCredentials: true,
+//		MaxAge:           600,
+//	}))
+func CORS(opts CORSOptions) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			if preflight {
+				h.Add("Vary", "Access-Control-Request-Method")
+				h.Add("Vary", "Access-Control-Request-Headers")
+			}
+
+			if origin == "" || !opts.allowOrigin(origin) {
+				next(w, r)
+				return
+			}
+
+			if preflight {
+				if !opts.preflight(w, r) {
+					next(w, r)
+					return
+				}
+				opts.setOrigin(h, origin)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			opts.setOrigin(h, origin)
+			if len(opts.ExposedHeaders) != 0 {
+				h.Set("Access-Control-Expose-Headers", strings.Join(opts.ExposedHeaders, ", "))
+			}
+			next(w, r)
+		}
+	}
+}
+
+// Report whether requests from origin are allowed.
+func (o CORSOptions) allowOrigin(origin string) bool {
+	lower := strings.ToLower(origin)
+	for _, allowed := range o.AllowedOrigins {
+		allowed = strings.ToLower(allowed)
+		if allowed == "*" || allowed == lower {
+			return true
+		}
+		if pre, suf, ok := strings.Cut(allowed, "*"); ok {
+			if len(lower) >= len(pre)+len(suf) && strings.HasPrefix(lower, pre) && strings.HasSuffix(lower, suf) {
+				return true
+			}
+		}
+	}
+	return o.AllowOriginFunc != nil && o.AllowOriginFunc(origin)
+}
+
+// Set allowed origin and credentials headers.
+func (o CORSOptions) setOrigin(h http.Header, origin string) {
+	if o.AllowCredentials {
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Set("Access-Control-Allow-Credentials", "true")
+		return
+	}
+	for _, allowed := range o.AllowedOrigins {
+		if allowed == "*" {
+			h.Set("Access-Control-Allow-Origin", "*")
+			return
+		}
+	}
+	h.Set("Access-Control-Allow-Origin", origin)
+}
+
+// Set preflight response headers if requested method and headers are allowed.
+// Reports whether preflight was accepted.
+func (o CORSOptions) preflight(w http.ResponseWriter, r *http.Request) bool {
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	methods, ok := allowedMethods(r)
+	switch {
+	case !ok:
+		// request was not routed or went to NotFound
+		methods = o.AllowedMethods
+	case contains(methods, "*"):
+		// route accepts any method, like a mounted handler, which knows
+		// its methods itself
+		methods = []string{method}
+	}
+	if ok && len(o.AllowedMethods) != 0 {
+		methods = intersect(methods, o.AllowedMethods)
+	}
+
+	if !contains(methods, method) {
+		return false
+	}
+
+	requested := splitHeaderList(r.Header.Get("Access-Control-Request-Headers"))
+	if len(o.AllowedHeaders) != 0 && !contains(o.AllowedHeaders, "*") {
+		for _, header := range requested {
+			if !contains(o.AllowedHeaders, header) {
+				return false
+			}
+		}
+	}
+
+	h := w.Header()
+	h.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	if len(requested) != 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+	}
+	if o.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(o.MaxAge))
+	}
+	return true
+}
+
+// Split comma separated header value to trimmed, canonicalized items.
+func splitHeaderList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, http.CanonicalHeaderKey(item))
+		}
+	}
+	return items
+}
+
+// Report whether list contains s, ignoring case.
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Return items of a that are also in b, ignoring case.
+func intersect(a, b []string) []string {
+	items := []string{}
+	for _, item := range a {
+		if contains(b, item) {
+			items = append(items, item)
+		}
+	}
+	return items
+}
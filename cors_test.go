@@ -0,0 +1,171 @@
+package snug_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/samharju/snug"
+)
+
+func TestCORSPreflight(t *testing.T) {
+	its := is.New(t)
+
+	r := snug.New()
+	r.UseMiddleware(snug.CORS(snug.CORSOptions{
+		AllowedOrigins: []string{"https://example.com", "https://*.example.org"},
+		AllowOriginFunc: func(origin string) bool {
+			return origin == "https://Func.test"
+		},
+		AllowedHeaders: []string{"Content-Type", "X-Token"},
+		MaxAge:         600,
+	}))
+	f := func(w http.ResponseWriter, r *http.Request) {}
+	r.Get("/items/<id>", f)
+	r.Delete("/items/<id>", f)
+
+	testcases := []struct {
+		name    string
+		origin  string
+		method  string
+		headers string
+		status  int
+		allowed string
+		methods string
+	}{
+		{"exact origin", "https://example.com", "DELETE", "x-token", 204, "https://example.com", "DELETE, GET, HEAD, OPTIONS"},
+		{"wildcard origin", "https://api.example.org", "GET", "", 204, "https://api.example.org", "DELETE, GET, HEAD, OPTIONS"},
+		{"func origin", "https://Func.test", "GET", "", 204, "https://Func.test", "DELETE, GET, HEAD, OPTIONS"},
+		{"origin not allowed", "https://evil.com", "GET", "", 204, "", ""},
+		{"wildcard does not match bare domain", "https://example.org", "GET", "", 204, "", ""},
+		{"method not registered", "https://example.com", "PUT", "", 204, "", ""},
+		{"header not allowed", "https://example.com", "GET", "X-Other", 204, "", ""},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("OPTIONS", "/items/1", nil)
+			req.Header.Set("Origin", tc.origin)
+			req.Header.Set("Access-Control-Request-Method", tc.method)
+			if tc.headers != "" {
+				req.Header.Set("Access-Control-Request-Headers", tc.headers)
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			its.Equal(rec.Code, tc.status)
+			its.Equal(rec.Header().Get("Access-Control-Allow-Origin"), tc.allowed)
+			its.Equal(rec.Header().Get("Access-Control-Allow-Methods"), tc.methods)
+		})
+	}
+
+	t.Run("preflight headers", func(t *testing.T) {
+		req := httptest.NewRequest("OPTIONS", "/items/1", nil)
+		req.Header.Set("Origin", "https://example.com")
+		req.Header.Set("Access-Control-Request-Method", "GET")
+		req.Header.Set("Access-Control-Request-Headers", "content-type, x-token")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		its.Equal(rec.Header().Get("Access-Control-Allow-Headers"), "Content-Type, X-Token")
+		its.Equal(rec.Header().Get("Access-Control-Max-Age"), "600")
+		its.Equal(rec.Header().Get("Access-Control-Allow-Credentials"), "")
+		its.Equal(strings.Join(rec.Header().Values("Vary"), ", "), "Origin, Access-Control-Request-Method, Access-Control-Request-Headers")
+	})
+}
+
+// preflights reaching a route, a mount or an explicit OPTIONS route, work
+// without AllowedMethods
+func TestCORSPreflightRoutes(t *testing.T) {
+	its := is.New(t)
+
+	r := snug.New()
+	r.LogRoutes = false
+	r.UseMiddleware(snug.CORS(snug.CORSOptions{AllowedOrigins: []string{"*"}}))
+	f := func(w http.ResponseWriter, r *http.Request) {}
+	r.Put("/items", f)
+	r.HandleFunc("OPTIONS", "/items", f)
+	r.Mount("/static", http.NotFoundHandler())
+
+	testcases := []struct {
+		name    string
+		path    string
+		method  string
+		methods string
+	}{
+		{"explicit options route", "/items", "PUT", "OPTIONS, PUT"},
+		{"explicit options route, method not registered", "/items", "DELETE", ""},
+		{"mount", "/static/app.js", "GET", "GET"},
+		{"mount any method", "/static/app.js", "PATCH", "PATCH"},
+		{"not found", "/nothing", "GET", ""},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("OPTIONS", tc.path, nil)
+			req.Header.Set("Origin", "https://example.com")
+			req.Header.Set("Access-Control-Request-Method", tc.method)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			its.Equal(rec.Header().Get("Access-Control-Allow-Methods"), tc.methods)
+		})
+	}
+
+	t.Run("AllowedMethods limits any method route", func(t *testing.T) {
+		r := snug.New()
+		r.LogRoutes = false
+		r.UseMiddleware(snug.CORS(snug.CORSOptions{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET"}}))
+		r.Mount("/static", http.NotFoundHandler())
+
+		for method, expected := range map[string]string{"GET": "GET", "PATCH": ""} {
+			req := httptest.NewRequest("OPTIONS", "/static/app.js", nil)
+			req.Header.Set("Origin", "https://example.com")
+			req.Header.Set("Access-Control-Request-Method", method)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			its.Equal(rec.Header().Get("Access-Control-Allow-Methods"), expected)
+		}
+	})
+}
+
+func TestCORSRequest(t *testing.T) {
+	its := is.New(t)
+
+	testcases := []struct {
+		name    string
+		opts    snug.CORSOptions
+		origin  string
+		allowed string
+		creds   string
+		exposed string
+	}{
+		{"any origin", snug.CORSOptions{AllowedOrigins: []string{"*"}}, "https://a.com", "*", "", ""},
+		{"any origin with credentials", snug.CORSOptions{AllowedOrigins: []string{"*"}, AllowCredentials: true}, "https://a.com", "https://a.com", "true", ""},
+		{"exposed headers", snug.CORSOptions{AllowedOrigins: []string{"https://a.com"}, ExposedHeaders: []string{"X-Total"}}, "https://a.com", "https://a.com", "", "X-Total"},
+		{"not allowed", snug.CORSOptions{AllowedOrigins: []string{"https://a.com"}}, "https://b.com", "", "", ""},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			called := false
+			r := snug.New()
+			r.UseMiddleware(snug.CORS(tc.opts))
+			r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+				called = true
+			})
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Origin", tc.origin)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			its.True(called) // handler must be called regardless of origin
+			its.Equal(rec.Header().Get("Access-Control-Allow-Origin"), tc.allowed)
+			its.Equal(rec.Header().Get("Access-Control-Allow-Credentials"), tc.creds)
+			its.Equal(rec.Header().Get("Access-Control-Expose-Headers"), tc.exposed)
+		})
+	}
+}
@@ -2,16 +2,45 @@ package snug
 
 import (
 	"encoding/json"
-	"fmt"
 	"io"
 	"reflect"
 	"strings"
+	"unicode"
 )
 
 // Decode response body to given struct pointer.
 //
-// Error returned is either for invalid json or missing a value for field marked as required.
-// Using snug-tag requires to use also json-tag.
+// Error returned is either a *DecodeError for invalid json or a
+// *ValidationError listing every field failing its validation rules, or a
+// *TagError for misconfigured snug-tags.
+//
+// Rules are separated by commas in snug-tag:
+//
+//	required      value is not the zero value
+//	notempty      string, slice or map is not empty, number is not zero
+//	min=n, max=n  number is at least or at most n, length for strings, slices and maps
+//	gt=n, lt=n    like min and max, but exclusive
+//	len=n         string, slice or map has length of exactly n
+//	oneof=a|b     value is one of listed values
+//	email         string is an email address
+//	url           string is an absolute url
+//	uuid          string is a uuid
+//	regex=expr    string matches the whole expression, must be the last rule
+//
+// Nested structs are validated too, also inside pointers, slices and maps.
+// Errors name the location of failing field, like 'items[2].address.zip'.
+//
+// Rules other than required are skipped for nil pointers, combine them with
+// required when a value must be present. A field missing a required value is
+// not checked against the rest of its rules.
+//
+// Fields are named in errors like encoding/json names them: by the name in
+// json-tag, or by the field name. Fields json ignores, like ones tagged with
+// json:"-", are not validated.
+//
+// Rules are compiled once per type of o. If snug-tags of o are misconfigured,
+// like a rule is unknown or does not apply to the field type, Fit returns a
+// *TagError without decoding. Use Check to catch these in tests.
 //
 // Due to restrictions in encoding/json, use a pointer type for fields that can hold
 // a falsy value. For example empty string or number of value 0 is not interpreted as missing.
@@ -23,40 +52,165 @@ import (
 //			Age  *int    `json:"age" snug:"required"`
 //		}
 //		err := snug.Fit(r.Body, &body)
+//		var ve *snug.ValidationError
+//		if errors.As(err, &ve) {
+//			ve.JSON().Write(w, 400)
+//			return
+//		}
 //		if err != nil {
-//			// use error for details
+//			snug.JSON{"error": err.Error()}.Write(w, 400)
 //			return
 //		}
 //		w.Write(200)
 //	}
 func Fit(data io.ReadCloser, o any) error {
+	var p *plan
+	if t := reflect.TypeOf(o); t != nil {
+		var err error
+		if p, err = planOf(t); err != nil {
+			return err
+		}
+	}
 	err := json.NewDecoder(data).Decode(o)
 	if err != nil {
-		return fmt.Errorf("invalid json: %w", err)
-	}
-	t := reflect.TypeOf(o).Elem()
-	v := reflect.ValueOf(o).Elem()
-	errMsgs := []string{}
-	for i := 0; i < t.NumField(); i++ {
-		tags, ok := t.Field(i).Tag.Lookup("snug")
-		if !ok {
-			continue
+		return &DecodeError{err}
+	}
+	var errs []FieldError
+	if err := p.validate(reflect.ValueOf(o), "", &errs); err != nil {
+		return err
+	}
+	if len(errs) != 0 {
+		return &ValidationError{errs}
+	}
+	return nil
+}
+
+// DecodeError is returned by Fit when request body is not valid json or does
+// not fit the target.
+type DecodeError struct {
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return "invalid json: " + e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError is returned by Fit when decoded values fail validation rules.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+// FieldError describes a single field failing a validation rule.
+type FieldError struct {
+	// location of field in json, like items[2].address.zip
+	Field string `json:"field"`
+	// rule that failed, like required or min
+	Rule string `json:"rule"`
+	// parameter of rule, like 3 for min=3
+	Param string `json:"param,omitempty"`
+	// description of failure, like 'items[2].address.zip' is a required field
+	Message string `json:"message"`
+}
+
+// Error lists messages of all failing fields.
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Message
+	}
+	return strings.Join(msgs, ", ")
+}
+
+// JSON returns error as a payload for responding to client:
+//
+//	{"error": "validation failed", "fields": [{"field": "name", "rule": "required", "message": "'name' is a required field"}]}
+//
+// Write it with status of choice:
+//
+//	var ve *snug.ValidationError
+//	if errors.As(err, &ve) {
+//		ve.JSON().Write(w, 422)
+//	}
+func (e *ValidationError) JSON() JSON {
+	return JSON{"error": "validation failed", "fields": e.Errors}
+}
+
+// TagError is returned by Check and Fit when snug-tags of a type are
+// misconfigured.
+type TagError struct {
+	// problems found, prefixed with struct type and field name
+	Problems []string
+}
+
+func (e *TagError) Error() string {
+	return strings.Join(e.Problems, ", ")
+}
+
+// Return name encoding/json uses for field f: name in json-tag or the field
+// name. Reports promoted for an embedded struct whose fields json treats as
+// fields of the outer struct, and false for a field json ignores.
+func jsonName(f reflect.StructField) (name string, promoted, ok bool) {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false, false
+	}
+	name, _, _ = strings.Cut(tag, ",")
+	if !validJSONName(name) {
+		name = ""
+	}
+	if f.Anonymous && name == "" {
+		t := f.Type
+		if t.Kind() == reflect.Pointer {
+			t = t.Elem()
 		}
-		jtags, ok := t.Field(i).Tag.Lookup("json")
-		if !ok {
-			panic("missing tag: json")
+		if t.Kind() == reflect.Struct {
+			return "", true, true
 		}
-		fieldName := strings.Split(jtags, ",")[0]
-		tagList := strings.Split(tags, ",")
-		for _, tag := range tagList {
-			if tag == "required" && v.Field(i).IsZero() {
-				errMsgs = append(errMsgs, fmt.Sprintf("'%s' is a required field", fieldName))
-			}
+	}
+	if !f.IsExported() {
+		return "", false, false
+	}
+	if name == "" {
+		name = f.Name
+	}
+	return name, false, true
+}
 
+// Report whether encoding/json accepts name given in json-tag.
+func validJSONName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if !strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c) && !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			return false
 		}
 	}
-	if len(errMsgs) != 0 {
-		return fmt.Errorf(strings.Join(errMsgs, ", "))
+	return true
+}
+
+// Check validates snug-tags of v, which is a struct or a pointer to one. Fit
+// returns the same error for every request it gets, so call Check in tests or
+// at startup to find out early:
+//
+//	func TestRequestTags(t *testing.T) {
+//		if err := snug.Check(createItemRequest{}); err != nil {
+//			t.Fatal(err)
+//		}
+//	}
+//
+// Returned error is a *TagError listing every unknown rule, invalid rule
+// parameter, rule applied to a type it does not support, like min on a bool,
+// and snug-tag on a field encoding/json ignores.
+func Check(v any) error {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return nil
 	}
-	return nil
+	_, err := planOf(t)
+	return err
 }
@@ -1,7 +1,10 @@
 package snug_test
 
 import (
+	"encoding/json"
+	"errors"
 	"io"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
@@ -100,19 +103,241 @@ func TestFitRequired(t *testing.T) {
 
 }
 
-func TestMissingJsonTag(t *testing.T) {
+func TestFitRules(t *testing.T) {
 	its := is.New(t)
 
-	type testcase struct {
-		a string `snug:"required"`
+	type teststruct struct {
+		Name  string            `json:"name" snug:"required,min=2,max=5"`
+		Email *string           `json:"email" snug:"email"`
+		Role  string            `json:"role" snug:"oneof=admin|user"`
+		Age   int               `json:"age" snug:"gt=0,lt=150"`
+		Tags  []string          `json:"tags" snug:"notempty,max=2"`
+		Meta  map[string]string `json:"meta" snug:"len=1"`
+		Code  string            `json:"code" snug:"regex=[A-Z]{2,3}"`
 	}
 
-	tc := testcase{a: "123"}
-	defer func() {
-		if r := recover(); r != nil {
-			its.Equal(r, "missing tag: json")
-		}
-	}()
-	snug.Fit(strreadcloser(`{"a": "abc"}`), &tc)
+	testcases := []struct {
+		name      string
+		input     string
+		errString string
+	}{
+		{
+			"valid",
+			`{"name": "abc", "role": "user", "age": 30, "tags": ["a"], "meta": {"k": "v"}, "code": "AB"}`,
+			"",
+		},
+		{
+			"all violations reported",
+			`{"name": "abcdef", "email": "nope", "role": "root", "age": 0, "tags": [], "meta": {}, "code": "ABCD"}`,
+			"'name' must contain at most 5 characters, " +
+				"'email' must be a valid email address, " +
+				"'role' must be one of admin, user, " +
+				"'age' must be greater than 0, " +
+				"'tags' must not be empty, " +
+				"'meta' must contain exactly 1 item, " +
+				"'code' must match [A-Z]{2,3}",
+		},
+		{
+			"required skips other rules of field",
+			`{"role": "admin", "age": 1, "tags": ["a", "b", "c"], "meta": {"k": "v"}, "code": "ABC"}`,
+			"'name' is a required field, " +
+				"'tags' must contain at most 2 items",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var o teststruct
+			err := snug.Fit(strreadcloser(tc.input), &o)
+			if tc.errString == "" {
+				its.NoErr(err)
+				return
+			}
+			its.True(err != nil)                 // expected err but got nil
+			its.Equal(err.Error(), tc.errString) // got != expected
+		})
+	}
+}
+
+func TestFitNested(t *testing.T) {
+	its := is.New(t)
+
+	type address struct {
+		Zip  string `json:"zip" snug:"required,len=5"`
+		City string `json:"city"`
+	}
+	type item struct {
+		Name    string   `json:"name" snug:"required"`
+		Address *address `json:"address"`
+	}
+	type Embedded struct {
+		Owner string `json:"owner" snug:"required"`
+	}
+	type teststruct struct {
+		Embedded
+		Items   []item              `json:"items" snug:"max=3"`
+		Primary address             `json:"primary"`
+		ByName  map[string]*address `json:"by_name"`
+		Nested  [][]item            `json:"nested"`
+		Ints    []int               `json:"ints"`
+	}
+
+	testcases := []struct {
+		name      string
+		input     string
+		errString string
+	}{
+		{
+			"valid",
+			`{"owner": "me", "primary": {"zip": "00100"}, "items": [{"name": "a"}, {"name": "b", "address": {"zip": "12345"}}]}`,
+			"",
+		},
+		{
+			"paths",
+			`{"owner": "me", "primary": {"zip": "1"}, "items": [{"name": "a"}, {}, {"name": "c", "address": {"city": "x"}}],
+			"by_name": {"b": {"zip": "123"}, "a": {}}, "nested": [[], [{"name": "x", "address": {"zip": "1"}}]]}`,
+			"'items[1].name' is a required field, " +
+				"'items[2].address.zip' is a required field, " +
+				"'primary.zip' must contain exactly 5 characters, " +
+				"'by_name[a].zip' is a required field, " +
+				"'by_name[b].zip' must contain exactly 5 characters, " +
+				"'nested[1][0].address.zip' must contain exactly 5 characters",
+		},
+		{
+			"embedded and rules of slice",
+			`{"primary": {"zip": "00100"}, "items": [{"name": "a"}, {"name": "b"}, {"name": "c"}, {}]}`,
+			"'owner' is a required field, " +
+				"'items' must contain at most 3 items, " +
+				"'items[3].name' is a required field",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var o teststruct
+			err := snug.Fit(strreadcloser(tc.input), &o)
+			if tc.errString == "" {
+				its.NoErr(err)
+				return
+			}
+			its.True(err != nil)                 // expected err but got nil
+			its.Equal(err.Error(), tc.errString) // got != expected
+		})
+	}
+}
+
+func TestFitErrors(t *testing.T) {
+	its := is.New(t)
+
+	type teststruct struct {
+		Name  string `json:"name" snug:"required"`
+		Items []struct {
+			Qty int `json:"qty" snug:"min=1"`
+		} `json:"items"`
+	}
+
+	var o teststruct
+	err := snug.Fit(strreadcloser(`{"name": 1}`), &o)
+	var de *snug.DecodeError
+	its.True(errors.As(err, &de)) // expected a decode error
+	var ute *json.UnmarshalTypeError
+	its.True(errors.As(err, &ute)) // decode error does not unwrap
+	var ve *snug.ValidationError
+	its.True(!errors.As(err, &ve)) // decode error is a validation error
 
+	err = snug.Fit(strreadcloser(`{"items": [{"qty": 1}, {"qty": 0}]}`), &o)
+	its.True(errors.As(err, &ve))  // expected a validation error
+	its.True(!errors.As(err, &de)) // validation error is a decode error
+	its.Equal(ve.Errors, []snug.FieldError{
+		{Field: "name", Rule: "required", Message: "'name' is a required field"},
+		{Field: "items[1].qty", Rule: "min", Param: "1", Message: "'items[1].qty' must be at least 1"},
+	})
+	its.Equal(err.Error(), "'name' is a required field, 'items[1].qty' must be at least 1")
+
+	rec := httptest.NewRecorder()
+	ve.JSON().Write(rec, 422)
+	its.Equal(rec.Code, 422)
+	its.Equal(rec.Body.String(), `{"error":"validation failed","fields":[`+
+		`{"field":"name","rule":"required","message":"'name' is a required field"},`+
+		`{"field":"items[1].qty","rule":"min","param":"1","message":"'items[1].qty' must be at least 1"}]}`)
+}
+
+// fields are named like encoding/json names them, no json-tag required
+func TestFitFieldNames(t *testing.T) {
+	its := is.New(t)
+
+	type Inner struct {
+		C string `snug:"required"`
+	}
+	type teststruct struct {
+		Inner
+		A       string `snug:"required"`
+		B       string `json:",omitempty" snug:"required"`
+		Ignored string `json:"-"`
+		Dash    string `json:"-," snug:"required"`
+		Bad     string `json:"a\"b" snug:"required"`
+		hidden  string
+	}
+
+	var o teststruct
+	err := snug.Fit(strreadcloser(`{}`), &o)
+	its.True(err != nil) // expected err but got nil
+	its.Equal(err.Error(), "'C' is a required field, 'A' is a required field, 'B' is a required field, "+
+		"'-' is a required field, 'Bad' is a required field")
+}
+
+func TestCheck(t *testing.T) {
+	its := is.New(t)
+
+	type valid struct {
+		Name string         `json:"name" snug:"required,min=1,regex=[a-z,]+"`
+		Age  *int           `json:"age" snug:"gt=0"`
+		Tags []string       `json:"tags" snug:"notempty,max=3"`
+		Next *valid         `json:"next"`
+		Any  interface{}    `json:"any" snug:"required"`
+		Skip map[string]int `json:"-"`
+	}
+	its.NoErr(snug.Check(valid{}))
+	its.NoErr(snug.Check(&valid{}))
+	its.NoErr(snug.Check(nil))
+
+	type Embedded struct {
+		E bool `snug:"email"`
+	}
+	type item struct {
+		Qty int `json:"qty" snug:"min=x"`
+	}
+	type invalid struct {
+		Embedded `snug:"required"`
+		A        string  `snug:"nope"`
+		B        bool    `snug:"min=1"`
+		C        *string `snug:"regex=[a-z"`
+		Items    []item  `json:"items"`
+		Skip     string  `json:"-" snug:"required"`
+		hidden   string  `snug:"required"`
+	}
+	err := snug.Check(invalid{})
+	var te *snug.TagError
+	its.True(errors.As(err, &te)) // expected a tag error
+	its.Equal(err.Error(), "snug_test.invalid.Embedded: snug tag on embedded struct, "+
+		"snug_test.Embedded.E: invalid rule: email does not apply to bool, "+
+		"snug_test.invalid.A: unknown rule: nope, "+
+		"snug_test.invalid.B: invalid rule: min does not apply to bool, "+
+		"snug_test.invalid.C: invalid rule: regex=[a-z: error parsing regexp: missing closing ]: `[a-z`, "+
+		"snug_test.item.Qty: invalid rule: min=x, "+
+		"snug_test.invalid.Skip: snug tag on field ignored by json, "+
+		"snug_test.invalid.hidden: snug tag on field ignored by json")
+
+	// Fit returns the same error instead of panicking
+	var o invalid
+	its.Equal(snug.Fit(strreadcloser(`{}`), &o), err)
+
+	// also for a misconfigured type found in an interface
+	type holder struct {
+		Any any `json:"any"`
+	}
+	h := holder{Any: &item{}}
+	err = snug.Fit(strreadcloser(`{"any": {"qty": 1}}`), &h)
+	its.True(errors.As(err, &te)) // expected a tag error
+	its.Equal(err.Error(), "snug_test.item.Qty: invalid rule: min=x")
 }
@@ -2,7 +2,6 @@ package snug
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
 )
 
@@ -17,7 +16,7 @@ type JSON map[string]any
 func (s JSON) dump() ([]byte, error) {
 	serialized, err := json.Marshal(s)
 	if err != nil {
-		log.Printf("ERROR: JSON.Dump: %s", err)
+		DefaultLogger.Error("json marshal failed", "error", err)
 		return []byte(`{"error": "internal server error"}`), err
 	}
 	return serialized, nil
@@ -0,0 +1,71 @@
+package snug
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Logger is a structured logger taking a message and alternating key-value
+// pairs. *slog.Logger satisfies Logger.
+//
+//	r := snug.New()
+//	r.Logger = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+type Logger interface {
+	Info(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// DefaultLogger is used when router has no Logger set and by JSON, which has
+// no access to router. Logs to the standard log package:
+//
+//	2022/09/28 19:21:45 INFO request method=GET path=/items status=200 bytes=56
+var DefaultLogger Logger = stdLogger{}
+
+// Discard is a Logger dropping everything, for silencing router.
+var Discard Logger = discard{}
+
+type stdLogger struct{}
+
+func (stdLogger) Info(msg string, args ...any) {
+	log.Print(format("INFO", msg, args))
+}
+
+func (stdLogger) Error(msg string, args ...any) {
+	log.Print(format("ERROR", msg, args))
+}
+
+// Format message and key-value pairs to a single line. Values containing
+// spaces are quoted.
+func format(level, msg string, args []any) string {
+	var b strings.Builder
+	b.WriteString(level)
+	b.WriteString(" ")
+	b.WriteString(msg)
+	for i := 0; i < len(args); i += 2 {
+		var value any = "!MISSING"
+		if i+1 < len(args) {
+			value = args[i+1]
+		}
+		v := fmt.Sprint(value)
+		if v == "" || strings.ContainsAny(v, " \t\n\"=") {
+			v = fmt.Sprintf("%q", v)
+		}
+		fmt.Fprintf(&b, " %v=%s", args[i], v)
+	}
+	return b.String()
+}
+
+type discard struct{}
+
+func (discard) Info(msg string, args ...any)  {}
+func (discard) Error(msg string, args ...any) {}
+
+// Return logger of router serving request, DefaultLogger if not set.
+func loggerOf(r *http.Request) Logger {
+	if rc := contextOf(r); rc != nil && rc.logger != nil {
+		return rc.logger
+	}
+	return DefaultLogger
+}
@@ -0,0 +1,111 @@
+package snug_test
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/samharju/snug"
+)
+
+type entry struct {
+	level string
+	msg   string
+	args  []any
+}
+
+// recordLogger collects log entries for assertions
+type recordLogger struct {
+	entries []entry
+}
+
+func (l *recordLogger) Info(msg string, args ...any) {
+	l.entries = append(l.entries, entry{"INFO", msg, args})
+}
+
+func (l *recordLogger) Error(msg string, args ...any) {
+	l.entries = append(l.entries, entry{"ERROR", msg, args})
+}
+
+func TestRouterLogger(t *testing.T) {
+	its := is.New(t)
+
+	l := &recordLogger{}
+	r := snug.New()
+	r.Logger = l
+	r.UseMiddleware(snug.Logging)
+	r.UseMiddleware(snug.Recover)
+	r.Get("/items/<id>", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("item"))
+	})
+	r.Group("/fail", func(g *snug.Router) {
+		g.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		})
+	})
+
+	its.Equal(l.entries, []entry{
+		{"INFO", "serving", []any{"method", "GET", "pattern", "/items/<id>"}},
+		{"INFO", "serving", []any{"method", "GET", "pattern", "/fail/"}},
+	})
+
+	l.entries = nil
+	req := httptest.NewRequest("GET", "/items/1", nil)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	its.Equal(len(l.entries), 1)
+	args := l.entries[0].args
+	its.Equal(args[:len(args)-1], []any{
+		"remote", req.RemoteAddr,
+		"method", "GET",
+		"path", "/items/1",
+		"pattern", "/items/<id>",
+		"status", 200,
+		"bytes", int64(4),
+		"duration",
+	})
+	_, ok := args[len(args)-1].(time.Duration)
+	its.True(ok) // duration is not a time.Duration
+
+	l.entries = nil
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/fail", nil))
+	its.Equal(len(l.entries), 1)
+	its.Equal(l.entries[0].level, "ERROR")
+	its.Equal(l.entries[0].args[:3], []any{"error", "boom", "stack"})
+}
+
+func TestLogRoutesDisabled(t *testing.T) {
+	its := is.New(t)
+
+	l := &recordLogger{}
+	r := snug.New()
+	r.Logger = l
+	r.LogRoutes = false
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {})
+	its.Equal(len(l.entries), 0)
+}
+
+func TestDefaultLogger(t *testing.T) {
+	its := is.New(t)
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	flags := log.Flags()
+	log.SetFlags(0)
+	defer log.SetFlags(flags)
+
+	snug.DefaultLogger.Info("msg", "a", 1, "b", "two words", "c", "", "d", fmt.Errorf("x=y"), "odd")
+	snug.DefaultLogger.Error("failed")
+	snug.Discard.Error("hidden")
+
+	its.Equal(strings.Split(buf.String(), "\n"), []string{
+		`INFO msg a=1 b="two words" c="" d="x=y" odd=!MISSING`,
+		`ERROR failed`,
+		``,
+	})
+}
@@ -0,0 +1,185 @@
+package snug
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBuckets are the upper bounds of request duration histogram buckets
+// in seconds, used by NewMetrics when no buckets are given.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metrics collects request counts, requests in flight and request duration
+// histograms, labelled by method, matched route pattern and status class.
+// Metrics are served in Prometheus text exposition format by ServeHTTP.
+//
+// Add Middleware with UseMiddleware so that matched route pattern is known:
+//
+//	m := snug.NewMetrics()
+//	r := snug.New()
+//	r.UseMiddleware(m.Middleware)
+//	r.Get("/metrics", m.ServeHTTP)
+//
+// Exposed metrics:
+//
+//	snug_http_requests_total{method="GET",route="/items/<id>",status="2xx"} 12
+//	snug_http_requests_in_flight 1
+//	snug_http_request_duration_seconds_bucket{method="GET",route="/items/<id>",status="2xx",le="0.005"} 10
+type Metrics struct {
+	buckets  []float64
+	inFlight int64
+
+	mu     sync.RWMutex
+	series map[seriesKey]*series
+}
+
+type seriesKey struct {
+	method string
+	route  string
+	status string
+}
+
+// series holds counters of a single label combination. Counters are
+// updated atomically, the map holding series is guarded by Metrics.mu.
+type series struct {
+	// observations per bucket, last one is +Inf, not cumulative
+	counts []uint64
+	// sum of observed seconds as float64 bits
+	sum uint64
+}
+
+// Create Metrics with histogram bucket upper bounds in seconds.
+// DefaultBuckets are used if none are given.
+func NewMetrics(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	b := append([]float64{}, buckets...)
+	sort.Float64s(b)
+	return &Metrics{
+		buckets: b,
+		series:  map[seriesKey]*series{},
+	}
+}
+
+// Middleware records metrics of requests passing through it.
+func (m *Metrics) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&m.inFlight, 1)
+		defer atomic.AddInt64(&m.inFlight, -1)
+
+		start := time.Now()
+		w := Record(rw)
+		next(w, r)
+		m.observe(seriesKey{
+			method: methodLabel(r.Method),
+			route:  Pattern(r),
+			status: strconv.Itoa(w.Status()/100) + "xx",
+		}, time.Since(start).Seconds())
+	}
+}
+
+// Record duration of a request to series of key.
+func (m *Metrics) observe(key seriesKey, seconds float64) {
+	m.mu.RLock()
+	s, ok := m.series[key]
+	m.mu.RUnlock()
+	if !ok {
+		m.mu.Lock()
+		if s, ok = m.series[key]; !ok {
+			s = &series{counts: make([]uint64, len(m.buckets)+1)}
+			m.series[key] = s
+		}
+		m.mu.Unlock()
+	}
+
+	i := sort.SearchFloat64s(m.buckets, seconds)
+	atomic.AddUint64(&s.counts[i], 1)
+	for {
+		old := atomic.LoadUint64(&s.sum)
+		sum := math.Float64bits(math.Float64frombits(old) + seconds)
+		if atomic.CompareAndSwapUint64(&s.sum, old, sum) {
+			break
+		}
+	}
+}
+
+// ServeHTTP writes metrics in Prometheus text exposition format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	type snapshot struct {
+		labels string
+		counts []uint64
+		total  uint64
+		sum    float64
+	}
+
+	m.mu.RLock()
+	snapshots := make([]snapshot, 0, len(m.series))
+	for key, s := range m.series {
+		snap := snapshot{
+			labels: fmt.Sprintf(`method="%s",route="%s",status="%s"`, escapeLabel(key.method), escapeLabel(key.route), key.status),
+			counts: make([]uint64, len(s.counts)),
+			sum:    math.Float64frombits(atomic.LoadUint64(&s.sum)),
+		}
+		for i := range s.counts {
+			snap.counts[i] = atomic.LoadUint64(&s.counts[i])
+			snap.total += snap.counts[i]
+		}
+		snapshots = append(snapshots, snap)
+	}
+	m.mu.RUnlock()
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].labels < snapshots[j].labels })
+
+	var b strings.Builder
+	b.WriteString("# HELP snug_http_requests_total Total number of HTTP requests.\n")
+	b.WriteString("# TYPE snug_http_requests_total counter\n")
+	for _, s := range snapshots {
+		fmt.Fprintf(&b, "snug_http_requests_total{%s} %d\n", s.labels, s.total)
+	}
+
+	b.WriteString("# HELP snug_http_requests_in_flight Number of HTTP requests being served.\n")
+	b.WriteString("# TYPE snug_http_requests_in_flight gauge\n")
+	fmt.Fprintf(&b, "snug_http_requests_in_flight %d\n", atomic.LoadInt64(&m.inFlight))
+
+	b.WriteString("# HELP snug_http_request_duration_seconds Duration of HTTP requests in seconds.\n")
+	b.WriteString("# TYPE snug_http_request_duration_seconds histogram\n")
+	for _, s := range snapshots {
+		var cumulative uint64
+		for i, upper := range m.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(&b, "snug_http_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n", s.labels, strconv.FormatFloat(upper, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(&b, "snug_http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", s.labels, s.total)
+		fmt.Fprintf(&b, "snug_http_request_duration_seconds_sum{%s} %s\n", s.labels, strconv.FormatFloat(s.sum, 'g', -1, 64))
+		fmt.Fprintf(&b, "snug_http_request_duration_seconds_count{%s} %d\n", s.labels, s.total)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(b.String()))
+}
+
+// Return method as a label value, nonstandard methods are grouped to keep
+// label cardinality bounded.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Escape backslash, double quote and newline in a label value.
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
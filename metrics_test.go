@@ -0,0 +1,108 @@
+package snug_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/samharju/snug"
+)
+
+func TestMetrics(t *testing.T) {
+	its := is.New(t)
+
+	m := snug.NewMetrics(0.05, 0.01)
+	r := snug.New()
+	r.UseMiddleware(m.Middleware)
+	r.Get("/items/<id>", func(w http.ResponseWriter, r *http.Request) {
+		if snug.Param(r, "id") == "slow" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		w.Write([]byte("ok"))
+	})
+	r.Get("/metrics", m.ServeHTTP)
+
+	for _, path := range []string{"/items/1", "/items/2", "/items/slow", "/none"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PROPFIND", "/items/1", nil))
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	its.Equal(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4; charset=utf-8")
+
+	body, err := io.ReadAll(rec.Body)
+	its.NoErr(err)
+	lines := strings.Split(string(body), "\n")
+
+	expected := []string{
+		`# TYPE snug_http_requests_total counter`,
+		`snug_http_requests_total{method="GET",route="",status="4xx"} 1`,
+		`snug_http_requests_total{method="GET",route="/items/<id>",status="2xx"} 3`,
+		`snug_http_requests_total{method="OTHER",route="",status="4xx"} 1`,
+		`snug_http_requests_in_flight 1`,
+		`# TYPE snug_http_request_duration_seconds histogram`,
+		`snug_http_request_duration_seconds_bucket{method="GET",route="/items/<id>",status="2xx",le="0.01"} 2`,
+		`snug_http_request_duration_seconds_bucket{method="GET",route="/items/<id>",status="2xx",le="0.05"} 3`,
+		`snug_http_request_duration_seconds_bucket{method="GET",route="/items/<id>",status="2xx",le="+Inf"} 3`,
+		`snug_http_request_duration_seconds_count{method="GET",route="/items/<id>",status="2xx"} 3`,
+	}
+	for _, e := range expected {
+		found := false
+		for _, l := range lines {
+			found = found || l == e
+		}
+		its.True(found) // expected line missing from exposition
+		if !found {
+			t.Log(e)
+		}
+	}
+}
+
+func TestMetricsConcurrent(t *testing.T) {
+	its := is.New(t)
+
+	m := snug.NewMetrics()
+	r := snug.New()
+	r.LogRoutes = false
+	r.UseMiddleware(m.Middleware)
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			}
+		}()
+	}
+	wg.Wait()
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	its.True(strings.Contains(rec.Body.String(), `snug_http_requests_total{method="GET",route="/",status="2xx"} 1000`))
+	its.True(strings.Contains(rec.Body.String(), "snug_http_requests_in_flight 0"))
+}
+
+func BenchmarkMetrics(b *testing.B) {
+	m := snug.NewMetrics()
+	r := snug.New()
+	r.LogRoutes = false
+	r.UseMiddleware(m.Middleware)
+	r.Get("/items/<id>", func(w http.ResponseWriter, r *http.Request) {})
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/items/1", nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		r.ServeHTTP(rw, req)
+	}
+}
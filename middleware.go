@@ -1,57 +1,97 @@
 package snug
 
 import (
-	"log"
 	"net/http"
+	"runtime/debug"
+	"time"
 )
 
-type logger struct {
-	http.ResponseWriter
-	status int
-	size   int
-}
-
-// Wrap call to responsewriter to capture status code in logger.
-func (l *logger) WriteHeader(s int) {
-	l.ResponseWriter.WriteHeader(s)
-	l.status = s
-}
-
-// Wrap call to responsewriter to capture response size in logger
-func (l *logger) Write(b []byte) (int, error) {
-	size, err := l.ResponseWriter.Write(b)
-	l.size = size
-	return size, err
-}
-
-// Log request info in a generic webserver way to stderr:
+// Log request info with Logger of router:
 //
-//	timestamp           | remote address             | method |status| response size | url path
+//	remote address | method | url path | route pattern | status | response size | duration | request id
 //
-// example:
+// example with DefaultLogger:
 //
-//	2022/09/28 19:21:45 | 161.251.242.12:33706       | GET    | 200 |     56 | /items
-//	2022/09/28 19:21:45 | 161.251.242.12:33706       | GET    | 405 |      0 | /favicon.ico
+//	2022/09/28 19:21:45 INFO request remote=161.251.242.12:33706 method=GET path=/greet/age/esa pattern=/greet/age/<name> status=200 bytes=56 duration=61.2µs
+//	2022/09/28 19:21:45 INFO request remote=161.251.242.12:33706 method=GET path=/favicon.ico pattern="" status=404 bytes=21 duration=8.1µs
 func Logging(next http.HandlerFunc) http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
-		w := &logger{ResponseWriter: rw}
+		start := time.Now()
+		w := Record(rw)
 		next(w, r)
-		log.Printf("| %-21s | %-6s | %d | %6d | %s", r.RemoteAddr, r.Method, w.status, w.size, r.URL.String())
+		args := []any{
+			"remote", r.RemoteAddr,
+			"method", r.Method,
+			"path", r.URL.Path,
+			"pattern", Pattern(r),
+			"status", w.Status(),
+			"bytes", w.Size(),
+			"duration", time.Since(start),
+		}
+		if id := RequestID(r); id != "" {
+			args = append(args, "request_id", id)
+		}
+		loggerOf(r).Info("request", args...)
 	}
 }
 
 // Middleware for catching panics.
-// When handler panics, server logs the error and responds with status code 500 and body:
+// When handler panics, server logs the error with a stack trace and responds
+// with status code 500 and body:
 //
 //	{"error": "internal server error"}
+//
+// If handler already started the response, only logging is done. Request ID
+// is included in log and body if request has one. Use RecoverWith for custom
+// handling.
 func Recover(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, req *http.Request) {
-		defer func() {
-			if r := recover(); r != nil {
-				log.Printf("Recover: panic: %s", r)
-				JSON{"error": "internal server error"}.Write(w, http.StatusInternalServerError)
-			}
-		}()
-		next(w, req)
+	return RecoverWith(handlePanic)(next)
+}
+
+// Create a middleware for catching panics, calling fn with the panic value
+// and stack trace of the panicking goroutine.
+//
+// w passed to fn is a *ResponseRecorder, so fn can check with Written if
+// the response has already started. Panics with http.ErrAbortHandler are
+// not recovered, to let net/http abort the response as it does.
+//
+//	r.UseMiddleware(snug.RecoverWith(func(w http.ResponseWriter, r *http.Request, v any, stack []byte) {
+//		report(v, stack)
+//		if !snug.Record(w).Written() {
+//			snug.JSON{"error": "oops"}.Write(w, 500)
+//		}
+//	}))
+func RecoverWith(fn func(w http.ResponseWriter, r *http.Request, v any, stack []byte)) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(rw http.ResponseWriter, req *http.Request) {
+			w := Record(rw)
+			defer func() {
+				if v := recover(); v != nil {
+					if v == http.ErrAbortHandler {
+						panic(v)
+					}
+					if hp, ok := v.(*handlerPanic); ok {
+						// panicked in a handler goroutine of Timeout
+						fn(w, req, hp.value, hp.stack)
+						return
+					}
+					fn(w, req, v, debug.Stack())
+				}
+			}()
+			next(w, req)
+		}
+	}
+}
+
+// Default panic handler of Recover.
+func handlePanic(w http.ResponseWriter, r *http.Request, v any, stack []byte) {
+	args := []any{"error", v}
+	if id := RequestID(r); id != "" {
+		args = append(args, "request_id", id)
+	}
+	args = append(args, "stack", string(stack))
+	loggerOf(r).Error("panic", args...)
+	if !Record(w).Written() {
+		writeError(w, r, http.StatusInternalServerError, "internal server error")
 	}
 }
@@ -47,6 +47,109 @@ func TestMiddleware(t *testing.T) {
 
 }
 
+func TestRouteMiddleware(t *testing.T) {
+	its := is.New(t)
+
+	calls := []string{}
+	mw := func(name string) snug.Middleware {
+		return func(next http.HandlerFunc) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				next(w, r)
+			}
+		}
+	}
+	h := func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler")
+	}
+
+	r := snug.New()
+	r.UseMiddleware(mw("router1"))
+	r.UseMiddleware(mw("router2"))
+	r.Get("/admin", h, mw("auth"), mw("audit"))
+	r.Get("/public", h)
+	r.Group("/g", func(g *snug.Router) {
+		g.UseMiddleware(mw("group"))
+		g.Delete("/x", h, mw("route"))
+	})
+	r.Mount("/m", http.HandlerFunc(h), mw("mount"))
+
+	testcases := []struct {
+		method   string
+		path     string
+		expected []string
+	}{
+		{"GET", "/admin", []string{"router2", "router1", "auth", "audit", "handler"}},
+		{"GET", "/public", []string{"router2", "router1", "handler"}},
+		{"DELETE", "/g/x", []string{"router2", "router1", "group", "route", "handler"}},
+		{"GET", "/m/any", []string{"router2", "router1", "mount", "handler"}},
+	}
+
+	for _, tc := range testcases {
+		calls = []string{}
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tc.method, tc.path, nil))
+		its.Equal(calls, tc.expected) // middleware called in wrong order
+	}
+
+	its.Equal(r.Routes()[0].Middleware, 4)
+	its.Equal(r.Routes()[2].Middleware, 4)
+}
+
+// middleware added with Use wraps every route and fallback regardless of call order
+func TestUse(t *testing.T) {
+	its := is.New(t)
+
+	calls := []string{}
+	mw := func(name string) snug.Middleware {
+		return func(next http.HandlerFunc) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				route, _ := snug.MatchedRoute(r)
+				calls = append(calls, name+" "+route.Pattern)
+				next(w, r)
+			}
+		}
+	}
+	h := func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler")
+	}
+
+	r := snug.New()
+	r.Get("/before/<id>", h)
+	r.Use(mw("a"))
+	r.UseMiddleware(mw("router"))
+	r.Get("/after", h, mw("route"))
+	r.Use(mw("b"))
+
+	testcases := []struct {
+		method   string
+		path     string
+		expected []string
+	}{
+		{"GET", "/before/1", []string{"a /before/<id>", "b /before/<id>", "handler"}},
+		{"GET", "/after", []string{"a /after", "b /after", "router /after", "route /after", "handler"}},
+		{"GET", "/missing", []string{"a ", "b ", "router "}},
+		{"POST", "/after", []string{"a ", "b ", "router "}},
+		{"OPTIONS", "/after", []string{"a ", "b ", "router "}},
+	}
+
+	for _, tc := range testcases {
+		calls = []string{}
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tc.method, tc.path, nil))
+		its.Equal(calls, tc.expected) // middleware called in wrong order
+	}
+}
+
+func TestUseGroup(t *testing.T) {
+	its := is.New(t)
+
+	defer func() {
+		its.Equal(recover(), "Use called on a group")
+	}()
+	snug.New().Group("/g", func(g *snug.Router) {
+		g.Use(snug.Logging)
+	})
+}
+
 func TestLogging(t *testing.T) {
 	its := is.New(t)
 
@@ -65,7 +168,7 @@ func TestLogging(t *testing.T) {
 
 	r.ServeHTTP(rec, req)
 	its.Equal(rec.Result().StatusCode, 200)
-	its.True(strings.HasSuffix(buf.String(), "| GET    | 200 |      4 | /\n")) // not sure what was logged
+	its.True(strings.Contains(buf.String(), "method=GET path=/ pattern=/ status=200 bytes=4 duration=")) // not sure what was logged
 
 }
 
@@ -93,3 +196,62 @@ func TestRecover(t *testing.T) {
 	fmt.Println(buf.String(), "test")
 
 }
+
+func TestRecoverWith(t *testing.T) {
+	its := is.New(t)
+
+	var (
+		value any
+		stack []byte
+	)
+	r := snug.New()
+	r.UseMiddleware(snug.RecoverWith(func(w http.ResponseWriter, r *http.Request, v any, s []byte) {
+		value, stack = v, s
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	r.HandleFunc("GET", "/", func(w http.ResponseWriter, r *http.Request) {
+		panic("custom")
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	its.Equal(rec.Code, http.StatusTeapot)
+	its.Equal(value, "custom")
+	its.True(strings.Contains(string(stack), "TestRecoverWith")) // stack does not point to panicking handler
+}
+
+func TestRecoverStartedResponse(t *testing.T) {
+	its := is.New(t)
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+
+	r := snug.New()
+	r.UseMiddleware(snug.Recover)
+	r.HandleFunc("GET", "/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("partial"))
+		panic("late")
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	its.Equal(rec.Code, http.StatusAccepted)
+	its.Equal(rec.Body.String(), "partial") // no error body appended to started response
+	its.True(strings.Contains(buf.String(), "ERROR panic error=late stack="))
+}
+
+func TestRecoverAbortHandler(t *testing.T) {
+	its := is.New(t)
+
+	r := snug.New()
+	r.UseMiddleware(snug.Recover)
+	r.HandleFunc("GET", "/", func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+
+	defer func() {
+		its.Equal(recover(), http.ErrAbortHandler) // abort must reach net/http
+	}()
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
@@ -0,0 +1,275 @@
+package snug
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// plans caches compiled validation by type, values are *cachedPlan.
+var plans sync.Map
+
+type cachedPlan struct {
+	plan *plan
+	err  error
+}
+
+// plan validates values of a single type. It is compiled from snug-tags once
+// per type, so that Fit only walks values and evaluates prepared rules.
+//
+// A nil plan validates nothing.
+type plan struct {
+	// validated fields of a struct
+	fields []fieldPlan
+	// plan of pointer, slice, array or map elements
+	elem *plan
+	// plan of an interface, resolved from type of value
+	dynamic bool
+}
+
+type fieldPlan struct {
+	index int
+	// name in json, empty if promoted
+	name string
+	// fields of an embedded struct are validated as fields of the outer struct
+	promoted bool
+	rules    []rule
+	plan     *plan
+}
+
+// Return plan for type t, compiling it on first use. Returns an error listing
+// problems found in snug-tags.
+func planOf(t reflect.Type) (*plan, error) {
+	if c, ok := plans.Load(t); ok {
+		cp := c.(*cachedPlan)
+		return cp.plan, cp.err
+	}
+	comp := compiler{plans: map[reflect.Type]*plan{}}
+	cp := &cachedPlan{plan: comp.prune(comp.compile(t))}
+	if len(comp.problems) != 0 {
+		cp.err = &TagError{comp.problems}
+	}
+	// keep plan of a concurrent call compiling the same type
+	c, _ := plans.LoadOrStore(t, cp)
+	cp = c.(*cachedPlan)
+	return cp.plan, cp.err
+}
+
+// compiler holds state of compiling a plan for a single type.
+type compiler struct {
+	// plans of structs compiled so far, types may be recursive
+	plans map[reflect.Type]*plan
+	// every plan compiled
+	all      []*plan
+	problems []string
+}
+
+// Return a new plan, recorded for pruning.
+func (c *compiler) plan(p plan) *plan {
+	c.all = append(c.all, &p)
+	return &p
+}
+
+// Compile plan for type t, nil if values of t can not hold anything to
+// validate. Plans of structs without rules are removed later by prune, until
+// then they stand in for recursive types.
+func (c *compiler) compile(t reflect.Type) *plan {
+	switch t.Kind() {
+	case reflect.Interface:
+		return c.plan(plan{dynamic: true})
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		if !walkable(t.Elem()) {
+			return nil
+		}
+		return c.plan(plan{elem: c.compile(t.Elem())})
+	case reflect.Struct:
+		if p, ok := c.plans[t]; ok {
+			return p
+		}
+		p := c.plan(plan{})
+		c.plans[t] = p
+		for i := 0; i < t.NumField(); i++ {
+			if fp, ok := c.field(t, i); ok {
+				p.fields = append(p.fields, fp)
+			}
+		}
+		return p
+	}
+	return nil
+}
+
+// Compile plan for field i of struct t. Reports false if there is nothing to
+// validate in the field.
+func (c *compiler) field(t reflect.Type, i int) (fieldPlan, bool) {
+	f := t.Field(i)
+	name, promoted, ok := jsonName(f)
+	tags, tagged := f.Tag.Lookup("snug")
+	if !ok {
+		if tagged {
+			c.problems = append(c.problems, fmt.Sprintf("%s.%s: snug tag on field ignored by json", t, f.Name))
+		}
+		return fieldPlan{}, false
+	}
+	fp := fieldPlan{index: i, name: name, promoted: promoted}
+	if promoted && tagged {
+		c.problems = append(c.problems, fmt.Sprintf("%s.%s: snug tag on embedded struct", t, f.Name))
+	}
+	if !promoted {
+		for _, ru := range parseRules(tags) {
+			ru, err := ru.compile(f.Type)
+			if err != nil {
+				c.problems = append(c.problems, fmt.Sprintf("%s.%s: %s", t, f.Name, err))
+				continue
+			}
+			fp.rules = append(fp.rules, ru)
+		}
+	}
+	fp.plan = c.compile(f.Type)
+	return fp, len(fp.rules) != 0 || fp.plan != nil
+}
+
+// Remove plans that validate nothing from compiled plans, so that validate
+// skips values without rules, like slices of structs without snug-tags.
+// Returns root, or nil if it validates nothing.
+func (c *compiler) prune(root *plan) *plan {
+	// a plan is live if it resolves an interface, has rules or refers to a
+	// live plan, repeat until recursive references settle
+	live := map[*plan]bool{}
+	for changed := true; changed; {
+		changed = false
+		for _, p := range c.all {
+			if live[p] {
+				continue
+			}
+			l := p.dynamic || live[p.elem]
+			for _, f := range p.fields {
+				l = l || len(f.rules) != 0 || live[f.plan]
+			}
+			if l {
+				live[p] = true
+				changed = true
+			}
+		}
+	}
+
+	for _, p := range c.all {
+		if !live[p.elem] {
+			p.elem = nil
+		}
+		fields := p.fields[:0]
+		for _, f := range p.fields {
+			if !live[f.plan] {
+				f.plan = nil
+			}
+			if len(f.rules) != 0 || f.plan != nil {
+				fields = append(fields, f)
+			}
+		}
+		p.fields = fields
+	}
+	if !live[root] {
+		return nil
+	}
+	return root
+}
+
+// Report whether values of t may contain structs to validate.
+func walkable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Struct, reflect.Interface:
+		return true
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return walkable(t.Elem())
+	}
+	return false
+}
+
+// Validate v, appending violations to errs. path is the location of v in the
+// decoded json, like items[2].address. Returns a *TagError if v holds a value
+// in an interface whose type has misconfigured snug-tags.
+func (p *plan) validate(v reflect.Value, path string, errs *[]FieldError) error {
+	if p == nil {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+		dp, err := planOf(v.Type())
+		if err != nil {
+			return err
+		}
+		return dp.validate(v, path, errs)
+	case reflect.Pointer:
+		if !v.IsNil() {
+			return p.elem.validate(v.Elem(), path, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		if p.elem == nil {
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := p.elem.validate(v.Index(i), path+"["+strconv.Itoa(i)+"]", errs); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if p.elem == nil {
+			return nil
+		}
+		// sorted for stable order of errors
+		type entry struct {
+			key  string
+			elem reflect.Value
+		}
+		entries := make([]entry, 0, v.Len())
+		for it := v.MapRange(); it.Next(); {
+			entries = append(entries, entry{fmt.Sprint(it.Key()), it.Value()})
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].key < entries[j].key
+		})
+		for _, e := range entries {
+			if err := p.elem.validate(e.elem, path+"["+e.key+"]", errs); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		for i := range p.fields {
+			if err := p.fields[i].validate(v.Field(p.fields[i].index), path, errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Validate field value v against rules of fp and descend into it.
+func (fp *fieldPlan) validate(v reflect.Value, path string, errs *[]FieldError) error {
+	if fp.promoted {
+		return fp.plan.validate(v, path, errs)
+	}
+	name := fp.name
+	if path != "" {
+		name = path + "." + name
+	}
+	for _, ru := range fp.rules {
+		if msg := ru.check(v); msg != "" {
+			*errs = append(*errs, FieldError{
+				Field:   name,
+				Rule:    ru.name,
+				Param:   ru.param,
+				Message: fmt.Sprintf("'%s' %s", name, msg),
+			})
+			if ru.name == "required" {
+				// other rules would only repeat the same
+				return nil
+			}
+		}
+	}
+	return fp.plan.validate(v, name, errs)
+}
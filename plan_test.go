@@ -0,0 +1,154 @@
+package snug
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+type planNode struct {
+	Name     string      `json:"name" snug:"required"`
+	Children []*planNode `json:"children"`
+}
+
+func TestPlanCache(t *testing.T) {
+	its := is.New(t)
+
+	typ := reflect.TypeOf(&planNode{})
+
+	plans := make([]*plan, 8)
+	var wg sync.WaitGroup
+	for i := range plans {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			p, err := planOf(typ)
+			its.NoErr(err)
+			plans[i] = p
+		}(i)
+	}
+	wg.Wait()
+
+	p, err := planOf(typ)
+	its.NoErr(err)
+	its.True(p != nil) // no plan compiled
+	for _, cached := range plans {
+		its.True(cached == p) // plan compiled again
+	}
+	its.True(p.elem == p.elem.fields[1].plan.elem.elem) // recursive type compiled twice
+
+	var errs []FieldError
+	p.validate(reflect.ValueOf(&planNode{Name: "a", Children: []*planNode{{}, {Name: "c"}}}), "", &errs)
+	its.Equal(len(errs), 1)
+	its.Equal(errs[0].Field, "children[0].name")
+
+	// types holding nothing to validate get no plan
+	type plain struct {
+		A string `json:"a"`
+	}
+	type plainNode struct {
+		Next     *plainNode         `json:"next"`
+		Children map[string][]plain `json:"children"`
+	}
+	for _, v := range []any{[]map[string]int{}, []plain{}, map[string]*plain{}, plainNode{}} {
+		p, err = planOf(reflect.TypeOf(v))
+		its.NoErr(err)
+		its.True(p == nil) // plan compiled for type without rules
+	}
+
+	// only fields leading to rules are kept, also through mutual recursion
+	p, err = planOf(reflect.TypeOf(mutualA{}))
+	its.NoErr(err)
+	its.Equal(len(p.fields), 1)
+	its.Equal(p.fields[0].name, "b")
+	its.Equal(len(p.fields[0].plan.elem.fields), 2) // fields a and n of mutualB
+}
+
+type mutualA struct {
+	Plain []struct{ X int } `json:"plain"`
+	B     []mutualB         `json:"b"`
+}
+
+type mutualB struct {
+	A *mutualA `json:"a"`
+	N string   `json:"n" snug:"required"`
+}
+
+type benchAddress struct {
+	Street string `json:"street" snug:"required,max=100"`
+	Zip    string `json:"zip" snug:"required,len=5,regex=[0-9]+"`
+	City   string `json:"city" snug:"required"`
+}
+
+type benchUser struct {
+	Name    *string       `json:"name" snug:"required,min=2,max=50"`
+	Email   *string       `json:"email" snug:"required,email"`
+	Age     *int          `json:"age" snug:"required,gt=0,lt=150"`
+	Role    string        `json:"role" snug:"oneof=admin|user|guest"`
+	Tags    []string      `json:"tags" snug:"max=10"`
+	Address *benchAddress `json:"address"`
+}
+
+type benchOrder struct {
+	ID    string `json:"id" snug:"required,uuid"`
+	Items []struct {
+		SKU string `json:"sku" snug:"required"`
+		Qty int    `json:"qty" snug:"gt=0"`
+	} `json:"items" snug:"notempty"`
+	Shipping benchAddress `json:"shipping"`
+}
+
+const (
+	benchUserJSON  = `{"name": "esa", "email": "esa@example.com", "age": 25, "role": "user", "tags": ["a", "b"], "address": {"street": "Main 1", "zip": "00100", "city": "Helsinki"}}`
+	benchOrderJSON = `{"id": "123e4567-e89b-12d3-a456-426614174000", "items": [{"sku": "a", "qty": 1}, {"sku": "b", "qty": 2}, {"sku": "c", "qty": 3}], "shipping": {"street": "Main 1", "zip": "00100", "city": "Helsinki"}}`
+)
+
+func benchmarkFit[T any](b *testing.B, body string) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var o T
+		if err := Fit(io.NopCloser(strings.NewReader(body)), &o); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// validation only, without decoding
+func benchmarkValidate[T any](b *testing.B, body string) {
+	var o T
+	if err := Fit(io.NopCloser(strings.NewReader(body)), &o); err != nil {
+		b.Fatal(err)
+	}
+	v := reflect.ValueOf(&o)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p, _ := planOf(v.Type())
+		var errs []FieldError
+		p.validate(v, "", &errs)
+		if len(errs) != 0 {
+			b.Fatal(errs)
+		}
+	}
+}
+
+// compiling a plan, paid once per type
+func benchmarkCompile[T any](b *testing.B) {
+	typ := reflect.TypeOf(new(T))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c := compiler{plans: map[reflect.Type]*plan{}}
+		c.compile(typ)
+	}
+}
+
+func BenchmarkFitUser(b *testing.B)       { benchmarkFit[benchUser](b, benchUserJSON) }
+func BenchmarkFitOrder(b *testing.B)      { benchmarkFit[benchOrder](b, benchOrderJSON) }
+func BenchmarkValidateUser(b *testing.B)  { benchmarkValidate[benchUser](b, benchUserJSON) }
+func BenchmarkValidateOrder(b *testing.B) { benchmarkValidate[benchOrder](b, benchOrderJSON) }
+func BenchmarkCompileUser(b *testing.B)   { benchmarkCompile[benchUser](b) }
+func BenchmarkCompileOrder(b *testing.B)  { benchmarkCompile[benchOrder](b) }
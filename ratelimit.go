@@ -0,0 +1,182 @@
+package snug
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitOptions configures RateLimit middleware.
+type RateLimitOptions struct {
+	// Limit is the number of requests allowed per Window for a key.
+	Limit int
+	// Window is the time in which Limit requests are allowed.
+	Window time.Duration
+	// Key identifies the client of a request. Defaults to KeyByIP.
+	Key func(r *http.Request) string
+	// Store keeps rate limit state. Defaults to a store created with
+	// NewMemoryRateLimitStore.
+	Store RateLimitStore
+}
+
+// RateLimitStore keeps rate limit state of keys. Implement it to share
+// limits between server instances, for example in a database.
+type RateLimitStore interface {
+	// Take consumes one request from the allowance of key, which is limit
+	// requests per window.
+	Take(key string, limit int, window time.Duration) (RateLimitResult, error)
+}
+
+// RateLimitResult is the outcome of RateLimitStore.Take.
+type RateLimitResult struct {
+	// Allowed reports whether request is within limit.
+	Allowed bool
+	// Remaining is the number of requests left right now.
+	Remaining int
+	// Reset is the time until allowance is fully restored.
+	Reset time.Duration
+	// RetryAfter is the time until next request is allowed, when not allowed.
+	RetryAfter time.Duration
+}
+
+// RateLimit returns a middleware limiting requests per client with a token
+// bucket: each key can burst up to Limit requests, and allowance refills
+// evenly over Window. Panics if Limit or Window is not positive.
+//
+// Responses carry RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset
+// headers. Requests over limit get status 429 with a Retry-After header and
+// body:
+//
+//	{"error": "too many requests"}
+//
+// If store returns an error, request is allowed and the error is logged.
+//
+//	r.UseMiddleware(snug.RateLimit(snug.RateLimitOptions{
+//		Limit:  100,
+//		Window: time.Minute,
+//		Key:    snug.KeyByHeader("X-Api-Key"),
+//	}))
+func RateLimit(opts RateLimitOptions) Middleware {
+	if opts.Limit <= 0 || opts.Window <= 0 {
+		panic("invalid rate limit: limit and window must be positive")
+	}
+	if opts.Key == nil {
+		opts.Key = KeyByIP
+	}
+	if opts.Store == nil {
+		opts.Store = NewMemoryRateLimitStore()
+	}
+
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			res, err := opts.Store.Take(opts.Key(r), opts.Limit, opts.Window)
+			if err != nil {
+				loggerOf(r).Error("rate limit store failed", "error", err)
+				next(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(opts.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", seconds(res.Reset))
+			if !res.Allowed {
+				h.Set("Retry-After", seconds(res.RetryAfter))
+				writeError(w, r, http.StatusTooManyRequests, "too many requests")
+				return
+			}
+			next(w, r)
+		}
+	}
+}
+
+// KeyByIP identifies client by remote address without port.
+func KeyByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// KeyByHeader returns a key func identifying client by value of header.
+// Requests without the header share a single allowance.
+func KeyByHeader(name string) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// Format duration as whole seconds, rounded up.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// MemoryRateLimitStore is an in-process RateLimitStore. Buckets that have
+// refilled completely are dropped periodically to keep memory bounded.
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+	now     func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Create an empty in-memory store.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+// Take consumes a token from bucket of key. Never returns an error.
+func (s *MemoryRateLimitStore) Take(key string, limit int, window time.Duration) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.swept) > window {
+		s.sweep(now, limit, window)
+	}
+
+	// tokens per second
+	rate := float64(limit) / window.Seconds()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit), last: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(limit), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	res := RateLimitResult{}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = time.Duration((float64(limit) - b.tokens) / rate * float64(time.Second))
+	return res, nil
+}
+
+// Drop buckets that would be full by now.
+func (s *MemoryRateLimitStore) sweep(now time.Time, limit int, window time.Duration) {
+	rate := float64(limit) / window.Seconds()
+	for key, b := range s.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*rate >= float64(limit) {
+			delete(s.buckets, key)
+		}
+	}
+	s.swept = now
+}
@@ -0,0 +1,47 @@
+package snug
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	its := is.New(t)
+
+	now := time.Unix(0, 0)
+	s := NewMemoryRateLimitStore()
+	s.now = func() time.Time { return now }
+
+	take := func() RateLimitResult {
+		res, err := s.Take("k", 4, 4*time.Second)
+		its.NoErr(err)
+		return res
+	}
+
+	for i := 3; i >= 0; i-- {
+		res := take()
+		its.True(res.Allowed)
+		its.Equal(res.Remaining, i)
+	}
+	res := take()
+	its.True(!res.Allowed)
+	its.Equal(res.RetryAfter, time.Second)
+	its.Equal(res.Reset, 4*time.Second)
+
+	// one token per second
+	now = now.Add(1500 * time.Millisecond)
+	res = take()
+	its.True(res.Allowed)
+	its.Equal(res.Remaining, 0)
+	res = take()
+	its.True(!res.Allowed)
+	its.Equal(res.RetryAfter, 500*time.Millisecond)
+
+	// full buckets are swept
+	now = now.Add(time.Minute)
+	s.Take("other", 4, 4*time.Second)
+	_, ok := s.buckets["k"]
+	its.True(!ok)
+}
@@ -0,0 +1,118 @@
+package snug_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/samharju/snug"
+)
+
+func TestRateLimit(t *testing.T) {
+	its := is.New(t)
+
+	r := snug.New()
+	r.UseMiddleware(snug.RateLimit(snug.RateLimitOptions{Limit: 2, Window: time.Hour}))
+	r.UseMiddleware(snug.AssignRequestID)
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	testcases := []struct {
+		remote     string
+		status     int
+		remaining  string
+		reset      string
+		retryAfter string
+	}{
+		{"10.0.0.1:1000", 200, "1", "1800", ""},
+		{"10.0.0.1:1001", 200, "0", "3600", ""},
+		{"10.0.0.1:1002", 429, "0", "3600", "1800"},
+		{"10.0.0.2:1000", 200, "1", "1800", ""},
+	}
+
+	for _, tc := range testcases {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tc.remote
+		req.Header.Set("X-Request-ID", "req-1")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		its.Equal(rec.Code, tc.status)
+		its.Equal(rec.Header().Get("RateLimit-Limit"), "2")
+		its.Equal(rec.Header().Get("RateLimit-Remaining"), tc.remaining)
+		its.Equal(rec.Header().Get("RateLimit-Reset"), tc.reset)
+		its.Equal(rec.Header().Get("Retry-After"), tc.retryAfter)
+		if tc.status == 429 {
+			var body map[string]string
+			its.NoErr(json.NewDecoder(rec.Body).Decode(&body))
+			its.Equal(body, map[string]string{"error": "too many requests", "request_id": "req-1"})
+		}
+	}
+}
+
+// fakeStore records keys and answers with a fixed result
+type fakeStore struct {
+	keys []string
+	res  snug.RateLimitResult
+	err  error
+}
+
+func (s *fakeStore) Take(key string, limit int, window time.Duration) (snug.RateLimitResult, error) {
+	s.keys = append(s.keys, key)
+	return s.res, s.err
+}
+
+func TestRateLimitStore(t *testing.T) {
+	its := is.New(t)
+
+	called := false
+	h := func(w http.ResponseWriter, r *http.Request) { called = true }
+
+	t.Run("custom store and key", func(t *testing.T) {
+		store := &fakeStore{res: snug.RateLimitResult{Allowed: false, RetryAfter: 1500 * time.Millisecond}}
+		mw := snug.RateLimit(snug.RateLimitOptions{
+			Limit:  10,
+			Window: time.Minute,
+			Key:    snug.KeyByHeader("X-Api-Key"),
+			Store:  store,
+		})
+
+		called = false
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Api-Key", "secret")
+		rec := httptest.NewRecorder()
+		mw(h)(rec, req)
+
+		its.True(!called)
+		its.Equal(store.keys, []string{"secret"})
+		its.Equal(rec.Code, 429)
+		its.Equal(rec.Header().Get("Retry-After"), "2")
+	})
+
+	t.Run("store error allows request", func(t *testing.T) {
+		r := snug.New()
+		r.Logger = snug.Discard
+		r.UseMiddleware(snug.RateLimit(snug.RateLimitOptions{
+			Limit:  1,
+			Window: time.Minute,
+			Store:  &fakeStore{err: errors.New("down")},
+		}))
+		r.Get("/", h)
+
+		called = false
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		its.True(called)
+		its.Equal(rec.Code, 200)
+	})
+
+	t.Run("invalid options panic", func(t *testing.T) {
+		defer func() {
+			its.Equal(recover(), "invalid rate limit: limit and window must be positive")
+		}()
+		snug.RateLimit(snug.RateLimitOptions{Limit: 1})
+	})
+}
@@ -0,0 +1,136 @@
+package snug
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+)
+
+// ResponseRecorder wraps a http.ResponseWriter to record status code and
+// response size for middleware.
+//
+// Status defaults to 200 when handler writes a body without calling
+// WriteHeader, like net/http does. Flushing, hijacking and io.ReaderFrom
+// are passed through to the wrapped writer, and http.ResponseController
+// can reach it with Unwrap.
+//
+//	func Sizes(next http.HandlerFunc) http.HandlerFunc {
+//		return func(rw http.ResponseWriter, r *http.Request) {
+//			w := snug.Record(rw)
+//			next(w, r)
+//			fmt.Println(w.Status(), w.Size())
+//		}
+//	}
+type ResponseRecorder struct {
+	http.ResponseWriter
+	status  int
+	size    int64
+	written bool
+}
+
+// Wrap w in a ResponseRecorder. If w already is a *ResponseRecorder, it is
+// returned as is, so stacked middleware share the recording.
+func Record(w http.ResponseWriter) *ResponseRecorder {
+	if rec, ok := w.(*ResponseRecorder); ok {
+		return rec
+	}
+	return &ResponseRecorder{ResponseWriter: w}
+}
+
+// Status returns the status code written, 200 if the response has not been
+// started.
+func (w *ResponseRecorder) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Size returns the number of body bytes written.
+func (w *ResponseRecorder) Size() int64 {
+	return w.size
+}
+
+// Written reports whether the response has been started, meaning status
+// code and headers are already sent.
+func (w *ResponseRecorder) Written() bool {
+	return w.written
+}
+
+// Unwrap returns the wrapped http.ResponseWriter for http.ResponseController.
+func (w *ResponseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Capture status code of response. Informational 1xx statuses do not start
+// the response.
+func (w *ResponseRecorder) WriteHeader(status int) {
+	w.ResponseWriter.WriteHeader(status)
+	if w.written || (status >= 100 && status < 200 && status != http.StatusSwitchingProtocols) {
+		return
+	}
+	w.status = status
+	w.written = true
+}
+
+// Accumulate size of response body over writes.
+func (w *ResponseRecorder) Write(b []byte) (int, error) {
+	w.start()
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// Copy src to response with io.ReaderFrom of wrapped writer if available.
+func (w *ResponseRecorder) ReadFrom(src io.Reader) (int64, error) {
+	w.start()
+	var (
+		n   int64
+		err error
+	)
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(src)
+	} else {
+		n, err = io.Copy(w.ResponseWriter, src)
+	}
+	w.size += n
+	return n, err
+}
+
+// Flush buffered data to client, if wrapped writer supports flushing.
+func (w *ResponseRecorder) Flush() {
+	w.FlushError()
+}
+
+// Flush buffered data to client, returning an error if wrapped writer does
+// not support flushing. Used by http.ResponseController.
+func (w *ResponseRecorder) FlushError() error {
+	switch f := w.ResponseWriter.(type) {
+	case interface{ FlushError() error }:
+		w.start()
+		return f.FlushError()
+	case http.Flusher:
+		w.start()
+		f.Flush()
+		return nil
+	}
+	return http.ErrNotSupported
+}
+
+// Take over the connection, if wrapped writer supports hijacking.
+func (w *ResponseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	return h.Hijack()
+}
+
+// Record implicit status 200 when body is written before WriteHeader.
+func (w *ResponseRecorder) start() {
+	if !w.written {
+		w.status = http.StatusOK
+		w.written = true
+	}
+}
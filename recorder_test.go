@@ -0,0 +1,133 @@
+package snug_test
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/samharju/snug"
+)
+
+func TestResponseRecorder(t *testing.T) {
+	its := is.New(t)
+
+	testcases := []struct {
+		name    string
+		handler http.HandlerFunc
+		status  int
+		size    int64
+		written bool
+	}{
+		{
+			"nothing written",
+			func(w http.ResponseWriter, r *http.Request) {},
+			200, 0, false,
+		},
+		{
+			"implicit status",
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("abc"))
+			},
+			200, 3, true,
+		},
+		{
+			"sizes accumulate",
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(201)
+				w.Write([]byte("abc"))
+				w.Write([]byte("defg"))
+			},
+			201, 7, true,
+		},
+		{
+			"first status wins",
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(404)
+				w.WriteHeader(500)
+			},
+			404, 0, true,
+		},
+		{
+			"informational status does not start response",
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(103)
+				w.WriteHeader(202)
+			},
+			202, 0, true,
+		},
+		{
+			"read from",
+			func(w http.ResponseWriter, r *http.Request) {
+				io.Copy(w, strings.NewReader("hello"))
+			},
+			200, 5, true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := snug.Record(httptest.NewRecorder())
+			tc.handler(w, httptest.NewRequest("GET", "/", nil))
+			its.Equal(w.Status(), tc.status)
+			its.Equal(w.Size(), tc.size)
+			its.Equal(w.Written(), tc.written)
+		})
+	}
+}
+
+func TestRecordReusesRecorder(t *testing.T) {
+	its := is.New(t)
+
+	w := snug.Record(httptest.NewRecorder())
+	its.True(snug.Record(w) == w) // recorder wrapped twice
+}
+
+type hijackWriter struct {
+	http.ResponseWriter
+	hijacked bool
+}
+
+func (w *hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.hijacked = true
+	return nil, nil, nil
+}
+
+func TestResponseRecorderInterfaces(t *testing.T) {
+	its := is.New(t)
+
+	t.Run("flush", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		w := snug.Record(rec)
+		w.Flush()
+		its.True(rec.Flushed)
+		its.True(w.Written())
+	})
+
+	t.Run("flush not supported", func(t *testing.T) {
+		w := snug.Record(&hijackWriter{ResponseWriter: httptest.NewRecorder()})
+		its.Equal(w.FlushError(), http.ErrNotSupported)
+	})
+
+	t.Run("hijack", func(t *testing.T) {
+		hw := &hijackWriter{ResponseWriter: httptest.NewRecorder()}
+		w := snug.Record(hw)
+		_, _, err := w.Hijack()
+		its.NoErr(err)
+		its.True(hw.hijacked)
+	})
+
+	t.Run("hijack not supported", func(t *testing.T) {
+		_, _, err := snug.Record(httptest.NewRecorder()).Hijack()
+		its.Equal(err, http.ErrNotSupported)
+	})
+
+	t.Run("unwrap", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		its.Equal(snug.Record(rec).Unwrap(), rec)
+	})
+}
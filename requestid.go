@@ -0,0 +1,80 @@
+package snug
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// RequestIDHeader is the header AssignRequestID reads and sets.
+const RequestIDHeader = "X-Request-ID"
+
+// AssignRequestID is a middleware giving each request an ID. ID is taken from
+// X-Request-ID request header if it is present and sane, otherwise a new
+// random ID is generated. ID is set to X-Request-ID response header and is
+// available to handlers and middleware with RequestID.
+//
+// Logging, Recover and default error responses of router include the ID:
+//
+//	{"error": "not found", "request_id": "4bf92f3577b34da6a3ce929d0e0e4736"}
+func AssignRequestID(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		if rc := contextOf(r); rc != nil {
+			// shared with middleware wrapping this one, so that order of
+			// registration does not matter
+			rc.requestID.Store(id)
+		} else {
+			r = r.WithContext(context.WithValue(r.Context(), contextVar("requestID"), id))
+		}
+		next(w, r)
+	}
+}
+
+// Pull request ID assigned by AssignRequestID, empty if not assigned.
+func RequestID(r *http.Request) string {
+	if rc := contextOf(r); rc != nil {
+		if id := rc.loadRequestID(); id != "" {
+			return id
+		}
+	}
+	id, _ := r.Context().Value(contextVar("requestID")).(string)
+	return id
+}
+
+// Report whether id from a client is acceptable: not empty, at most
+// 128 characters and printable ascii.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// Generate a random 128 bit ID in hex.
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("snug: reading random bytes: " + err.Error())
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// Write JSON error response, with request ID if request has one.
+func writeError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	body := JSON{"error": msg}
+	if id := RequestID(r); id != "" {
+		body["request_id"] = id
+	}
+	body.Write(w, status)
+}
@@ -0,0 +1,172 @@
+package snug_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/samharju/snug"
+)
+
+func TestAssignRequestID(t *testing.T) {
+	its := is.New(t)
+
+	var seen string
+	r := snug.New()
+	r.UseMiddleware(snug.AssignRequestID)
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		seen = snug.RequestID(r)
+	})
+
+	testcases := []struct {
+		name     string
+		header   string
+		expected string
+	}{
+		{"from header", "abc-123", "abc-123"},
+		{"generated", "", ""},
+		{"invalid header replaced", "has space", ""},
+		{"too long header replaced", strings.Repeat("a", 129), ""},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if tc.header != "" {
+				req.Header.Set("X-Request-ID", tc.header)
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			its.Equal(rec.Header().Get("X-Request-ID"), seen) // response header != id in handler
+			if tc.expected != "" {
+				its.Equal(seen, tc.expected)
+				return
+			}
+			its.Equal(len(seen), 32)
+			its.True(seen != tc.header)
+		})
+	}
+
+	t.Run("outside router", func(t *testing.T) {
+		h := snug.AssignRequestID(func(w http.ResponseWriter, r *http.Request) {
+			seen = snug.RequestID(r)
+		})
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Request-ID", "outside")
+		h(httptest.NewRecorder(), req)
+		its.Equal(seen, "outside")
+	})
+
+	its.Equal(snug.RequestID(httptest.NewRequest("GET", "/", nil)), "")
+}
+
+func TestRequestIDInResponses(t *testing.T) {
+	its := is.New(t)
+
+	l := &recordLogger{}
+	r := snug.New()
+	r.Logger = l
+	r.LogRoutes = false
+	// Logging and Recover wrap AssignRequestID, and still see the id
+	r.UseMiddleware(snug.AssignRequestID)
+	r.UseMiddleware(snug.Recover)
+	r.UseMiddleware(snug.Logging)
+	r.Get("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	r.Mount("/inner", snug.New())
+
+	testcases := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/none", 404, "not found"},
+		{"/panic", 500, "internal server error"},
+		{"/inner/none", 404, "not found"},
+	}
+
+	for _, tc := range testcases {
+		l.entries = nil
+		req := httptest.NewRequest("GET", tc.path, nil)
+		req.Header.Set("X-Request-ID", "req-1")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		its.Equal(rec.Code, tc.status)
+		var body map[string]string
+		its.NoErr(json.NewDecoder(rec.Body).Decode(&body))
+		its.Equal(body, map[string]string{"error": tc.body, "request_id": "req-1"})
+
+		last := l.entries[len(l.entries)-1]
+		its.Equal(last.msg, "request")
+		its.Equal(last.args[len(last.args)-2:], []any{"request_id", "req-1"})
+
+		if tc.status == 500 {
+			its.Equal(l.entries[0].msg, "panic")
+			its.Equal(l.entries[0].args[:4], []any{"error", "boom", "request_id", "req-1"})
+		}
+	}
+}
+
+// request ID assigned on the handler goroutine of Timeout is read by the
+// serving goroutine, run with -race
+func TestRequestIDTimeout(t *testing.T) {
+	its := is.New(t)
+
+	slow := func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			next(w, r)
+		}
+	}
+
+	r := snug.New()
+	r.LogRoutes = false
+	r.Logger = snug.Discard
+	r.UseMiddleware(snug.Logging)
+	r.UseMiddleware(snug.Timeout(5 * time.Millisecond))
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {}, snug.AssignRequestID, slow)
+
+	for i := 0; i < 10; i++ {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(snug.RequestIDHeader, "abc")
+		r.ServeHTTP(rec, req)
+		its.Equal(rec.Code, 503)
+
+		var body map[string]string
+		its.NoErr(json.Unmarshal(rec.Body.Bytes(), &body))
+		if id, ok := body["request_id"]; ok {
+			its.Equal(id, "abc") // wrong request id in timeout response
+		}
+	}
+}
+
+func TestRequestIDHeaderOnTimeout(t *testing.T) {
+	its := is.New(t)
+
+	r := snug.New()
+	r.LogRoutes = false
+	r.UseMiddleware(snug.AssignRequestID)
+	r.UseMiddleware(snug.Timeout(5 * time.Millisecond))
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Inner", "1")
+		<-r.Context().Done()
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	its.Equal(rec.Code, 503)
+
+	var body map[string]string
+	its.NoErr(json.Unmarshal(rec.Body.Bytes(), &body))
+	its.True(body["request_id"] != "")
+	its.Equal(rec.Header().Get(snug.RequestIDHeader), body["request_id"]) // request id header dropped
+	its.Equal(rec.Header().Get("X-Inner"), "")                            // handler headers sent with timeout
+}
@@ -0,0 +1,145 @@
+package snug
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Route is a handler registered to a method and a pattern.
+type Route struct {
+	method  string
+	pattern string
+	name    string
+	handler http.HandlerFunc
+	// url parameters in the order they appear in pattern
+	params []param
+	// last parameter is a named wildcard capturing rest of the path
+	catchall bool
+	// number of middleware wrapping handler
+	middleware int
+	// router holding the routing table
+	router *Router
+	// tree node holding routes registered to the same pattern
+	node *node
+}
+
+// RouteInfo describes a registered route.
+type RouteInfo struct {
+	Method  string
+	Pattern string
+	// Name given with Route.Name, empty if not named
+	Name string
+	// Params are names of url parameters in pattern, including a named wildcard
+	Params []string
+	// Middleware is the number of middleware wrapping the handler
+	Middleware int
+}
+
+// param is a url parameter in a route pattern.
+type param struct {
+	name       string
+	constraint *constraint
+}
+
+func (rt *Route) info() RouteInfo {
+	params := make([]string, len(rt.params))
+	for i, p := range rt.params {
+		params[i] = p.name
+	}
+	return RouteInfo{
+		Method:     rt.method,
+		Pattern:    rt.pattern,
+		Name:       rt.name,
+		Params:     params,
+		Middleware: rt.middleware,
+	}
+}
+
+// Map url parameter names of route to values captured from request path.
+func (rt *Route) paramMap(values []string) map[string]string {
+	params := make(map[string]string, len(rt.params))
+	for i, p := range rt.params {
+		params[p.name] = values[i]
+	}
+	return params
+}
+
+// Name route for building urls with Router.URL. Panics if name is already
+// used by another route of the router.
+//
+//	r.Get("/items/<id:int>", getItem).Name("item.detail")
+func (rt *Route) Name(name string) *Route {
+	if other, ok := rt.router.names[name]; ok && other != rt {
+		panic("duplicate route name: " + name)
+	}
+	if rt.name != "" {
+		delete(rt.router.names, rt.name)
+	}
+	if rt.router.names == nil {
+		rt.router.names = map[string]*Route{}
+	}
+	rt.name = name
+	rt.router.names[name] = rt
+	return rt
+}
+
+// Build url path of route by substituting url parameters in pattern with
+// values. Values are escaped and must satisfy parameter constraints.
+func (rt *Route) url(values map[string]string) (string, error) {
+	for key := range values {
+		if !rt.hasParam(key) {
+			return "", fmt.Errorf("route %s: unknown url parameter: %s", rt.name, key)
+		}
+	}
+
+	segs := strings.Split(strings.Trim(rt.pattern, "/"), "/")
+	i := 0
+	for j, seg := range segs {
+		if !isParam(seg) && !isWildcard(seg) {
+			continue
+		}
+		if seg == "*" {
+			// unnamed wildcard builds the prefix only
+			segs = segs[:j]
+			break
+		}
+		p := rt.params[i]
+		i++
+		value, ok := values[p.name]
+		if !ok {
+			return "", fmt.Errorf("route %s: missing url parameter: %s", rt.name, p.name)
+		}
+		if !p.constraint.match(value) {
+			return "", fmt.Errorf("route %s: url parameter %s: %q does not match %s", rt.name, p.name, value, p.constraint.source())
+		}
+		if isWildcard(seg) {
+			rest := strings.Split(value, "/")
+			for k := range rest {
+				rest[k] = url.PathEscape(rest[k])
+			}
+			segs[j] = strings.Join(rest, "/")
+			continue
+		}
+		if value == "" {
+			return "", fmt.Errorf("route %s: url parameter %s: empty value", rt.name, p.name)
+		}
+		if strings.Contains(value, "/") {
+			// router matches decoded path, an escaped slash would not route back
+			return "", fmt.Errorf("route %s: url parameter %s: %q contains a slash", rt.name, p.name, value)
+		}
+		segs[j] = url.PathEscape(value)
+	}
+	return "/" + strings.Join(segs, "/"), nil
+}
+
+// Report whether route has a url parameter with name.
+func (rt *Route) hasParam(name string) bool {
+	for _, p := range rt.params {
+		if p.name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -1,32 +1,57 @@
 package snug
 
 import (
-	"context"
-	"log"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 )
 
 // Initialize new snug router.
 func New() *Router {
 	return &Router{
-		routes:           []endpoint{},
-		NotFound:         func(w http.ResponseWriter, r *http.Request) { JSON{"error": "not found"}.Write(w, 404) },
-		MethodNotAllowed: func(w http.ResponseWriter, r *http.Request) { JSON{"error": "method not allowed"}.Write(w, 405) },
+		tree:             &node{},
+		NotFound:         func(w http.ResponseWriter, r *http.Request) { writeError(w, r, 404, "not found") },
+		MethodNotAllowed: func(w http.ResponseWriter, r *http.Request) { writeError(w, r, 405, "method not allowed") },
+		Options:          func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) },
+		LogRoutes:        true,
 	}
 }
 
 type Router struct {
-	Prefix     string
-	routes     []endpoint
-	middleware []Middleware
+	Prefix string
+	// CaseSensitive disables case folding of static path segments, so that
+	// /ReadMe and /readme are different routes. Set before registering routes.
+	CaseSensitive bool
+	tree          *node
+	middleware    []Middleware
+	// middleware added with Use and the chain composed of them around dispatch
+	dispatch []Middleware
+	chain    http.HandlerFunc
+	// routes in registration order
+	routes []*Route
+	// named routes
+	names map[string]*Route
+	// router of a group, routes are registered through it
+	parent *Router
 	// NotFound is called when no route matches url.
 	// Default handler returns status 404 and a response body:
 	// {"error": "not found"}
 	NotFound http.HandlerFunc
 	// MethodNotAllowed is called when a pattern matches but method for that pattern does not match.
-	// Default handler returns status 405 with no body.
+	// Router sets the Allow header before calling it.
+	// Default handler returns status 405 and a response body:
+	// {"error": "method not allowed"}
 	MethodNotAllowed http.HandlerFunc
+	// Options is called for OPTIONS requests when a pattern matches but has no OPTIONS route.
+	// Router sets the Allow header before calling it.
+	// Default handler returns status 204 with no body.
+	Options http.HandlerFunc
+	// Logger receives log output of router and middleware. Defaults to DefaultLogger.
+	Logger Logger
+	// LogRoutes logs each registered route. Enabled by New.
+	LogRoutes bool
 }
 
 // Middleware accepts a http.HandlerFunc and returns a http.HandlerFunc.
@@ -43,6 +68,8 @@ type Router struct {
 type Middleware func(http.HandlerFunc) http.HandlerFunc
 
 // Slap given middleware to all handlers to be registered on this router.
+// Middleware added later wraps the ones added before it, so the last one
+// added runs first. Middleware given to HandleFunc run inside all of these.
 //
 //		r := snug.New()
 //		mw := func(hf http.HandlerFunc) http.HandlerFunc {
@@ -61,24 +88,45 @@ type Middleware func(http.HandlerFunc) http.HandlerFunc
 //	 // middleware called
 //	 // handler called
 func (r *Router) UseMiddleware(mw Middleware) {
-	r.NotFound = mw(r.NotFound)
-	r.MethodNotAllowed = mw(r.MethodNotAllowed)
+	if r.parent == nil {
+		// a group has no handlers of its own for unmatched requests
+		r.NotFound = mw(r.NotFound)
+		r.MethodNotAllowed = mw(r.MethodNotAllowed)
+		r.Options = mw(r.Options)
+	}
 	r.middleware = append(r.middleware, mw)
 }
 
-func urlParams(pattern, path string) (params map[string]string) {
-	pathParts := strings.Split(strings.Trim(path, "/"), "/")
-	patternParts := strings.Split(strings.ToLower(strings.Trim(pattern, "/")), "/")
-	params = map[string]string{}
-	for i, p := range patternParts {
-		if strings.HasPrefix(p, "<") {
-			params[strings.Trim(p, "<>")] = pathParts[i]
-		}
+// Use adds middleware run around dispatch of every request served by this
+// router. Unlike UseMiddleware, call order relative to HandleFunc does not
+// matter: every route and the NotFound, MethodNotAllowed and Options handlers
+// see the same chain. The first one added is the outermost, and all of them
+// run outside middleware added with UseMiddleware or given to HandleFunc.
+//
+// Route is resolved before the chain runs, so middleware can inspect it with
+// Pattern, Param and MatchedRoute:
+//
+//	r := snug.New()
+//	r.Use(snug.AssignRequestID, snug.Logging)
+//	r.Get("/items/<id>", getItem) // AssignRequestID -> Logging -> getItem
+//
+// Use applies to the router whose ServeHTTP serves the request and panics when
+// called on a group.
+func (r *Router) Use(mw ...Middleware) {
+	if r.parent != nil {
+		panic("Use called on a group")
+	}
+	r.dispatch = append(r.dispatch, mw...)
+	r.chain = dispatch
+	for i := len(r.dispatch) - 1; i >= 0; i-- {
+		r.chain = r.dispatch[i](r.chain)
 	}
-	return
 }
 
-type contextVar string
+// Call handler resolved for request by router.
+func dispatch(w http.ResponseWriter, r *http.Request) {
+	contextOf(r).handler(w, r)
+}
 
 // Pull url parameter with name.
 //
@@ -88,113 +136,419 @@ type contextVar string
 //		val2 := snug.Param(r, "best") // beer
 //	})
 func Param(r *http.Request, key string) string {
-	value, ok := r.Context().Value(contextVar("params")).(map[string]string)
-	if !ok {
+	rc := contextOf(r)
+	if rc == nil {
 		return ""
 	}
-	return value[key]
+	return rc.params[key]
+}
+
+// Pull url parameter with name parsed as an int. Returns an error if parameter
+// is missing or not an int. Use an int constraint in pattern to have
+// non-int values fall through to other routes:
+//
+//	r.HandleFunc("GET", "/items/<id:int>", func(w http.ResponseWriter, r *http.Request) {
+//		// Request path /items/42
+//		id, err := snug.ParamInt(r, "id") // 42, nil
+//	})
+func ParamInt(r *http.Request, key string) (int, error) {
+	value := Param(r, key)
+	if value == "" {
+		return 0, fmt.Errorf("missing url parameter: %s", key)
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("url parameter %s: %w", key, err)
+	}
+	return i, nil
+}
+
+// Merge url parameters captured by an outer router to params, parameters of
+// the inner router take precedence.
+func mergeParams(params, outer map[string]string) map[string]string {
+	if params == nil {
+		return outer
+	}
+	for k, v := range outer {
+		if _, ok := params[k]; !ok {
+			params[k] = v
+		}
+	}
+	return params
 }
 
 // ServeHTTP routes request to appropriate handler.
 func (ro Router) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
-	var (
-		hf         http.HandlerFunc
-		notallowed bool
-		hasParams  bool
-	)
-	path := strings.Split(strings.ToLower(strings.Trim(r.URL.Path, "/")), "/")
-
-	for _, v := range ro.routes {
-		hf, notallowed, hasParams = v.match(r.Method, path)
-		if hf != nil {
-			if hasParams {
-				params := urlParams(v.pattern, r.URL.Path)
-				r = r.WithContext(context.WithValue(r.Context(), contextVar("params"), params))
-			}
+	raw := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	path := raw
+	if !ro.CaseSensitive {
+		path = make([]string, len(raw))
+		for i := range raw {
+			path[i] = strings.ToLower(raw[i])
+		}
+	}
+	s := search{method: r.Method, path: path, raw: raw}
+
+	rc := &routeContext{logger: ro.Logger}
+	if outer := contextOf(r); outer != nil {
+		// mounted router
+		if rc.logger == nil {
+			rc.logger = outer.logger
+		}
+		rc.params = outer.params
+		if id := outer.loadRequestID(); id != "" {
+			rc.requestID.Store(id)
+		}
+	}
 
-			hf.ServeHTTP(rw, r)
-			return
+	switch {
+	case ro.tree != nil && ro.tree.find(&s, 0):
+		if s.endpoint.catchall {
+			s.values = append(s.values, strings.Join(raw[s.rest:], "/"))
+		}
+		if len(s.endpoint.params) != 0 {
+			rc.params = mergeParams(s.endpoint.paramMap(s.values), rc.params)
 		}
+		rc.route = s.endpoint
+		rc.handler = s.endpoint.handler
+	case len(s.matched) != 0:
+		rc.allow = s.allow()
+		rw.Header().Set("Allow", strings.Join(rc.allow, ", "))
+		rc.handler = ro.MethodNotAllowed
+		if r.Method == http.MethodOptions {
+			rc.handler = ro.Options
+		}
+	default:
+		rc.handler = ro.NotFound
 	}
-	if notallowed {
-		ro.MethodNotAllowed.ServeHTTP(rw, r)
+
+	r = withRouteContext(r, rc)
+	if ro.chain != nil {
+		ro.chain(rw, r)
 		return
 	}
-	ro.NotFound.ServeHTTP(rw, r)
+	rc.handler(rw, r)
+}
+
+// Return methods allowed for request path. Router resolves them when calling
+// MethodNotAllowed and Options, for a matched route they are the methods
+// registered to its pattern, including "*" for a route matching any method.
+// Reports false if request was not routed or no route matched.
+func allowedMethods(r *http.Request) ([]string, bool) {
+	rc := contextOf(r)
+	switch {
+	case rc == nil:
+		return nil, false
+	case rc.allow != nil:
+		return rc.allow, true
+	case rc.route != nil:
+		return methodsOf([]*node{rc.route.node}), true
+	}
+	return nil, false
 }
 
-func parsePattern(pattern string) (bool, []string) {
-	p := strings.Split(strings.ToLower(strings.Trim(pattern, "/")), "/")
+// Split pattern to segments and collect url parameter names. Static segments
+// are lowercased unless caseSensitive is set, parameters keep their case.
+// Panics if a parameter is not enclosed in angle brackets, has an invalid
+// constraint or if wildcard is not the last segment.
+func parsePattern(pattern string, caseSensitive bool) (segs []string, params []param, catchall bool) {
+	segs = strings.Split(strings.Trim(pattern, "/"), "/")
 
-	for i := range p {
-		pre := strings.HasPrefix(p[i], "<")
-		suf := strings.HasSuffix(p[i], ">")
-		if pre || suf {
-			if !(pre && suf) {
+	for i, seg := range segs {
+		pre := strings.HasPrefix(seg, "<")
+		suf := strings.HasSuffix(seg, ">")
+		if pre != suf {
+			panic("malformed pattern: " + pattern)
+		}
+		if isWildcard(seg) {
+			if i != len(segs)-1 {
 				panic("malformed pattern: " + pattern)
 			}
-			return true, p
+			if name := seg[1:]; name != "" {
+				params = append(params, param{name: name})
+				catchall = true
+			}
+			continue
+		}
+		if !pre {
+			if !caseSensitive {
+				segs[i] = strings.ToLower(seg)
+			}
+			continue
+		}
+		name, expr := parseParam(seg)
+		c, err := newConstraint(expr)
+		if err != nil {
+			panic("malformed pattern: " + pattern + ": " + err.Error())
 		}
+		params = append(params, param{name, c})
 	}
-	return false, p
+	return segs, params, catchall
 }
 
-// Register http.HandleFunc to given method and path.
-func (r *Router) HandleFunc(method, path string, f http.HandlerFunc) {
+// Return Logger of r, DefaultLogger if not set.
+func (r *Router) logger() Logger {
+	if r.Logger != nil {
+		return r.Logger
+	}
+	return DefaultLogger
+}
+
+// Apply Prefix of r to path.
+func (r *Router) prefixed(path string) string {
 	if r.Prefix != "" {
 		path = strings.Trim(r.Prefix, "/") + "/" + strings.TrimLeft(path, "/")
 	}
 	if !strings.HasPrefix(path, "/") {
 		path = "/" + path
 	}
+	return path
+}
 
-	hasparams, p := parsePattern(path)
+// Apply prefixes of r and its parents to path.
+func (r *Router) resolve(path string) string {
+	for ; r != nil; r = r.parent {
+		path = r.prefixed(path)
+	}
+	return path
+}
 
-	for _, v := range r.routes {
-		hf, _, _ := v.match(method, p)
-		if hf != nil {
-			panic("duplicate route and method: " + path + ", " + method)
-		}
+// Register http.HandleFunc to given method and path.
+//
+// Path segments in angle brackets are url parameters, available to handler
+// with Param. A parameter can restrict accepted values with a constraint after
+// a colon: int, uuid or a regular expression matching the whole segment.
+// Requests with values not satisfying the constraint fall through to other
+// routes. A trailing * matches the rest of the path, which is available with
+// Param when the wildcard is named, like *rest.
+//
+//	r.HandleFunc("GET", "/items/<id:int>", getItem)
+//	r.HandleFunc("GET", "/items/<slug:[a-z-]+>", getItemBySlug)
+//	r.HandleFunc("GET", "/users/<user:uuid>/*", getUserStuff)
+//	r.HandleFunc("GET", "/static/*file", getFile) // GET /static/css/app.css: Param(r, "file") == "css/app.css"
+//
+// Middleware given to HandleFunc wraps only this route, inside middleware of
+// the router. The first one given is the outermost:
+//
+//	r.UseMiddleware(snug.Logging)
+//	r.HandleFunc("DELETE", "/items/<id>", deleteItem, auth, audit) // Logging -> auth -> audit -> deleteItem
+//
+// Returned route can be named for building urls with URL.
+func (r *Router) HandleFunc(method, path string, f http.HandlerFunc, mw ...Middleware) *Route {
+	for i := len(mw) - 1; i >= 0; i-- {
+		f = mw[i](f)
 	}
+	return r.handle(method, path, f, len(mw))
+}
+
+// Register f to routing table through parent routers, applying prefix and
+// middleware of each. wrapped counts middleware applied so far.
+func (r *Router) handle(method, path string, f http.HandlerFunc, wrapped int) *Route {
+	path = r.prefixed(path)
 
 	for _, mw := range r.middleware {
 		f = mw(f)
 	}
-	e := endpoint{
-		method:  strings.ToUpper(method),
-		pattern: path,
-		path:    p,
-		handler: f,
-		params:  hasparams,
+	wrapped += len(r.middleware)
+	if r.parent != nil {
+		return r.parent.handle(method, path, f, wrapped)
+	}
+
+	segs, params, catchall := parsePattern(path, r.CaseSensitive)
+	method = strings.ToUpper(method)
+
+	if r.tree == nil {
+		r.tree = &node{}
+	}
+	n := r.tree.insert(segs)
+	if _, ok := n.endpoints[method]; ok {
+		panic("duplicate route and method: " + path + ", " + method)
 	}
-	if p[len(p)-1] == "*" {
-		e.wildcard = true
+	if n.endpoints == nil {
+		n.endpoints = map[string]*Route{}
 	}
-	r.routes = append(r.routes, e)
-	log.Printf("Serving %s %s", method, path)
+	route := &Route{
+		method:     method,
+		pattern:    path,
+		handler:    f,
+		params:     params,
+		catchall:   catchall,
+		middleware: wrapped,
+		router:     r,
+		node:       n,
+	}
+	n.endpoints[method] = route
+	r.routes = append(r.routes, route)
+	if r.LogRoutes {
+		r.logger().Info("serving", "method", method, "pattern", path)
+	}
+	return route
+}
+
+// Create a sub-router for registering routes under prefix.
+//
+// Routes of the group are registered to the routing table of r, so the group
+// does not need to be served separately. The group inherits middleware of r,
+// and middleware added to the group wraps only routes of the group, inside
+// the middleware of r. Groups can be nested. fn is called with the group
+// for scoping registrations and can be nil.
+//
+// Only Prefix of the group is used. CaseSensitive, LogRoutes, Logger and the
+// NotFound, MethodNotAllowed and Options handlers are those of the root
+// router, which serves the requests, and middleware added to the group does
+// not wrap them.
+//
+//	r := snug.New()
+//	r.UseMiddleware(snug.Logging)
+//	r.Group("/admin", func(g *snug.Router) {
+//		g.UseMiddleware(auth)
+//		g.Get("/users", listUsers) // GET /admin/users: Logging -> auth -> listUsers
+//	})
+func (r *Router) Group(prefix string, fn func(g *Router)) *Router {
+	if r.tree == nil {
+		r.tree = &node{}
+	}
+	g := &Router{
+		Prefix: prefix,
+		tree:   r.tree,
+		parent: r,
+	}
+	if fn != nil {
+		fn(g)
+	}
+	return g
+}
+
+// Forward all requests under prefix to h, which can be another snug router
+// or any http.Handler, such as http.FileServer.
+//
+// Prefix is stripped from request path before calling h, like http.StripPrefix
+// does. Url parameters captured from prefix are available with Param in h.
+// Routes registered to r under prefix take precedence over the mount.
+// Middleware given wraps only the mount, like with HandleFunc.
+//
+//	items := snug.New()
+//	items.Get("/detail", itemDetail)
+//
+//	r := snug.New()
+//	r.Mount("/items/<id>", items)                               // GET /items/42/detail -> itemDetail, Param(r, "id") == "42"
+//	r.Mount("/static", http.FileServer(http.Dir("./public")))   // GET /static/app.js -> ./public/app.js
+func (r *Router) Mount(prefix string, h http.Handler, mw ...Middleware) {
+	n := 0
+	if full := strings.Trim(r.resolve(prefix), "/"); full != "" {
+		n = strings.Count(full, "/") + 1
+	}
+	r.HandleFunc("*", strings.TrimRight(prefix, "/")+"/*", func(w http.ResponseWriter, req *http.Request) {
+		h.ServeHTTP(w, stripSegments(req, n))
+	}, mw...)
+}
+
+// Return a shallow copy of r with n leading segments removed from url path.
+func stripSegments(r *http.Request, n int) *http.Request {
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = trimSegments(r.URL.Path, n)
+	if r.URL.RawPath != "" {
+		r2.URL.RawPath = trimSegments(r.URL.RawPath, n)
+	}
+	return r2
+}
+
+// Remove n leading segments from path. Returned path always starts with a slash.
+func trimSegments(path string, n int) string {
+	path = strings.TrimLeft(path, "/")
+	for ; n > 0; n-- {
+		i := strings.IndexByte(path, '/')
+		if i < 0 {
+			return "/"
+		}
+		path = path[i+1:]
+	}
+	return "/" + path
 }
 
 // Register http.Handler to given method and path.
-func (r *Router) Handle(method, path string, h http.Handler) {
-	r.HandleFunc(method, path, h.ServeHTTP)
+func (r *Router) Handle(method, path string, h http.Handler, mw ...Middleware) *Route {
+	return r.HandleFunc(method, path, h.ServeHTTP, mw...)
 }
 
 // Register handler to path with GET.
-func (r *Router) Get(path string, hf http.HandlerFunc) {
-	r.HandleFunc("GET", path, hf)
+func (r *Router) Get(path string, hf http.HandlerFunc, mw ...Middleware) *Route {
+	return r.HandleFunc("GET", path, hf, mw...)
 }
 
 // Register handler to path with POST.
-func (r *Router) Post(path string, hf http.HandlerFunc) {
-	r.HandleFunc("POST", path, hf)
+func (r *Router) Post(path string, hf http.HandlerFunc, mw ...Middleware) *Route {
+	return r.HandleFunc("POST", path, hf, mw...)
 }
 
 // Register handler to path with PUT.
-func (r *Router) Put(path string, hf http.HandlerFunc) {
-	r.HandleFunc("PUT", path, hf)
+func (r *Router) Put(path string, hf http.HandlerFunc, mw ...Middleware) *Route {
+	return r.HandleFunc("PUT", path, hf, mw...)
 }
 
 // Register handler to path with DELETE.
-func (r *Router) Delete(path string, hf http.HandlerFunc) {
-	r.HandleFunc("DELETE", path, hf)
+func (r *Router) Delete(path string, hf http.HandlerFunc, mw ...Middleware) *Route {
+	return r.HandleFunc("DELETE", path, hf, mw...)
+}
+
+// Build url path for route registered with name. Url parameter values are
+// given as key-value pairs. Returns an error if route is unknown, a parameter
+// is missing or unknown, or a value does not satisfy parameter constraint.
+// Only a named wildcard accepts a value containing a slash.
+//
+//	r.Prefix = "/api"
+//	r.Get("/items/<id:int>", getItem).Name("item.detail")
+//	path, err := r.URL("item.detail", "id", "42") // "/api/items/42", nil
+func (r *Router) URL(name string, pairs ...string) (string, error) {
+	for r.parent != nil {
+		r = r.parent
+	}
+	route, ok := r.names[name]
+	if !ok {
+		return "", fmt.Errorf("unknown route: %s", name)
+	}
+	if len(pairs)%2 != 0 {
+		return "", fmt.Errorf("route %s: odd number of url parameter pairs", name)
+	}
+	values := make(map[string]string, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		if _, ok := values[pairs[i]]; ok {
+			return "", fmt.Errorf("route %s: duplicate url parameter: %s", name, pairs[i])
+		}
+		values[pairs[i]] = pairs[i+1]
+	}
+	return route.url(values)
+}
+
+// Return information of all routes in the routing table, in registration
+// order. Routes of groups are included, as they share the table.
+func (r *Router) Routes() []RouteInfo {
+	routes := []RouteInfo{}
+	r.Walk(func(ri RouteInfo) error {
+		routes = append(routes, ri)
+		return nil
+	})
+	return routes
+}
+
+// Call fn for each route in the routing table, in registration order.
+// Walking stops at the first error returned by fn, which Walk returns.
+//
+//	r.Walk(func(ri snug.RouteInfo) error {
+//		fmt.Printf("%-6s %-30s %s\n", ri.Method, ri.Pattern, ri.Name)
+//		return nil
+//	})
+func (r *Router) Walk(fn func(RouteInfo) error) error {
+	for r.parent != nil {
+		r = r.parent
+	}
+	for _, route := range r.routes {
+		if err := fn(route.info()); err != nil {
+			return err
+		}
+	}
+	return nil
 }
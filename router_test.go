@@ -1,9 +1,11 @@
 package snug_test
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"testing/fstest"
 
 	"github.com/matryer/is"
 	"github.com/samharju/snug"
@@ -171,6 +173,124 @@ func TestHandleSubrouter(t *testing.T) {
 	its.Equal(rw.Result().StatusCode, 202) // actual status != expected
 }
 
+func TestGroup(t *testing.T) {
+	its := is.New(t)
+
+	calls := []string{}
+	mw := func(name string) snug.Middleware {
+		return func(next http.HandlerFunc) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				next(w, r)
+			}
+		}
+	}
+	f := func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler")
+	}
+
+	r := snug.New()
+	r.Prefix = "/api"
+	r.UseMiddleware(mw("root"))
+	r.Get("/public", f)
+
+	admin := r.Group("/admin", func(g *snug.Router) {
+		g.UseMiddleware(mw("admin"))
+		g.Get("/users", f)
+		g.Group("/audit", func(g *snug.Router) {
+			g.UseMiddleware(mw("audit"))
+			g.Get("/log", f)
+		})
+	})
+	admin.Post("/users", f)
+
+	testcases := []struct {
+		method   string
+		path     string
+		expected []string
+	}{
+		{"GET", "/api/public", []string{"root", "handler"}},
+		{"GET", "/api/admin/users", []string{"root", "admin", "handler"}},
+		{"POST", "/api/admin/users", []string{"root", "admin", "handler"}},
+		{"GET", "/api/admin/audit/log", []string{"root", "admin", "audit", "handler"}},
+	}
+
+	for _, tc := range testcases {
+		calls = []string{}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+		its.Equal(rec.Code, 200)
+		its.Equal(calls, tc.expected) // middleware called in wrong order
+	}
+
+	// group does not register routes outside its prefix
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/api/users", nil))
+	its.Equal(rec.Code, 404)
+
+	// not found is handled by root, without middleware of the group
+	calls = []string{}
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/api/admin/none", nil))
+	its.Equal(rec.Code, 404)
+	its.Equal(calls, []string{"root"}) // group middleware wrapped not found
+
+	// settings of root apply to routes of the group
+	r.CaseSensitive = true
+	admin.Get("/Report", f)
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/api/admin/Report", nil))
+	its.Equal(rec.Code, 200)
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/api/admin/report", nil))
+	its.Equal(rec.Code, 404)
+}
+
+func TestMount(t *testing.T) {
+	its := is.New(t)
+
+	inner := snug.New()
+	inner.Get("/detail/<part>", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(snug.Param(r, "id") + " " + snug.Param(r, "part") + " " + r.URL.Path))
+	})
+
+	files := fstest.MapFS{"app.js": &fstest.MapFile{Data: []byte("console.log()")}}
+
+	r := snug.New()
+	r.Prefix = "/api"
+	r.Mount("/items/<id>", inner)
+	r.Get("/items/<id>/own", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("outer"))
+	})
+	r.Group("/assets", func(g *snug.Router) {
+		g.Mount("/static/", http.FileServer(http.FS(files)))
+	})
+
+	testcases := []struct {
+		name     string
+		method   string
+		path     string
+		status   int
+		expected string
+	}{
+		{"params from both routers", "GET", "/api/items/42/detail/Head", 200, "42 Head /detail/Head"},
+		{"inner not allowed", "POST", "/api/items/42/detail/head", 405, `{"error":"method not allowed"}`},
+		{"inner not found", "GET", "/api/items/42/other", 404, `{"error":"not found"}`},
+		{"outer route wins", "GET", "/api/items/42/own", 200, "outer"},
+		{"file server in group", "GET", "/api/assets/static/app.js", 200, "console.log()"},
+		{"file server not found", "GET", "/api/assets/static/none.js", 404, "404 page not found\n"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+			its.Equal(rec.Code, tc.status)
+			its.Equal(rec.Body.String(), tc.expected)
+		})
+	}
+}
+
 func TestUrlparams(t *testing.T) {
 	its := is.New(t)
 
@@ -220,6 +340,357 @@ func TestUrlparams(t *testing.T) {
 
 }
 
+func TestTypedParams(t *testing.T) {
+	its := is.New(t)
+
+	r := snug.New()
+	r.Get("/items/<id:int>", func(w http.ResponseWriter, r *http.Request) {
+		id, err := snug.ParamInt(r, "id")
+		its.NoErr(err)
+		w.Write([]byte(fmt.Sprintf("int %d", id)))
+	})
+	r.Get("/items/<slug:[A-Za-z-]+>", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("slug " + snug.Param(r, "slug")))
+	})
+	r.Get("/users/<user:uuid>", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("uuid " + snug.Param(r, "user")))
+	})
+	r.Get("/users/<name>", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("name " + snug.Param(r, "name")))
+	})
+
+	testcases := []struct {
+		path     string
+		status   int
+		expected string
+	}{
+		{"/items/42", 200, "int 42"},
+		{"/items/Hello-World", 200, "slug Hello-World"},
+		{"/items/abc_1", 404, `{"error":"not found"}`},
+		{"/users/123e4567-e89b-12d3-a456-426614174000", 200, "uuid 123e4567-e89b-12d3-a456-426614174000"},
+		{"/users/esa", 200, "name esa"},
+	}
+
+	for _, tc := range testcases {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("GET", tc.path, nil))
+		its.Equal(rec.Code, tc.status)
+		its.Equal(rec.Body.String(), tc.expected)
+	}
+
+	t.Run("param int errors", func(t *testing.T) {
+		r := snug.New()
+		r.Get("/<v>", func(w http.ResponseWriter, r *http.Request) {
+			_, err := snug.ParamInt(r, "v")
+			its.Equal(err.Error(), `url parameter v: strconv.Atoi: parsing "abc": invalid syntax`)
+			_, err = snug.ParamInt(r, "none")
+			its.Equal(err.Error(), "missing url parameter: none")
+		})
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/abc", nil))
+	})
+
+	t.Run("invalid constraint panics", func(t *testing.T) {
+		defer func() {
+			its.Equal(recover(), "malformed pattern: /x/<v:[a-z>: error parsing regexp: missing closing ]: `[a-z`")
+		}()
+		snug.New().Get("/x/<v:[a-z>", func(w http.ResponseWriter, r *http.Request) {})
+	})
+}
+
+func TestNamedWildcard(t *testing.T) {
+	its := is.New(t)
+
+	r := snug.New()
+	r.Get("/static/*rest", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(snug.Param(r, "rest")))
+	})
+	r.Get("/users/<id>/files/*path", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(snug.Param(r, "id") + " " + snug.Param(r, "path")))
+	})
+
+	testcases := []struct {
+		path     string
+		expected string
+	}{
+		{"/static/a/b/c", "a/b/c"},
+		{"/static/A/B.css", "A/B.css"},
+		{"/static", ""},
+		{"/users/1/files/docs/ReadMe.md", "1 docs/ReadMe.md"},
+	}
+
+	for _, tc := range testcases {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("GET", tc.path, nil))
+		its.Equal(rec.Code, 200)
+		its.Equal(rec.Body.String(), tc.expected) // captured rest of path != expected
+	}
+
+	t.Run("named and unnamed wildcard are duplicates", func(t *testing.T) {
+		defer func() {
+			its.Equal(recover(), "duplicate route and method: /static/*, GET")
+		}()
+		r.Get("/static/*", func(w http.ResponseWriter, r *http.Request) {})
+	})
+}
+
+func TestAllowedMethods(t *testing.T) {
+	its := is.New(t)
+
+	f := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Method", r.Method)
+		w.Write([]byte("body"))
+	}
+
+	r := snug.New()
+	r.Get("/items/<id>", f)
+	r.Put("/items/<id>", f)
+	r.Delete("/items/<id:int>", f)
+	r.Post("/items/new", f)
+	r.HandleFunc("HEAD", "/explicit", f)
+	r.Get("/explicit", f)
+	r.HandleFunc("OPTIONS", "/custom", f)
+
+	testcases := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		allow  string
+		body   string
+	}{
+		{"not allowed lists all matching routes", "POST", "/items/1", 405, "DELETE, GET, HEAD, OPTIONS, PUT", `{"error":"method not allowed"}`},
+		{"constraint limits allowed", "PATCH", "/items/abc", 405, "GET, HEAD, OPTIONS, PUT", `{"error":"method not allowed"}`},
+		{"automatic options", "OPTIONS", "/items/new", 204, "GET, HEAD, OPTIONS, POST, PUT", ""},
+		{"explicit options", "OPTIONS", "/custom", 200, "", "body"},
+		{"head served by get", "HEAD", "/items/1", 200, "", "body"},
+		{"explicit head", "HEAD", "/explicit", 200, "", "body"},
+		{"options not found", "OPTIONS", "/none", 404, "", `{"error":"not found"}`},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+			its.Equal(rec.Code, tc.status)
+			its.Equal(rec.Header().Get("Allow"), tc.allow)
+			its.Equal(rec.Body.String(), tc.body)
+		})
+	}
+
+	t.Run("head keeps headers", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("HEAD", "/items/1", nil))
+		its.Equal(rec.Header().Get("X-Method"), "HEAD")
+	})
+}
+
+func TestHeadServedByGet(t *testing.T) {
+	its := is.New(t)
+
+	flushers := map[string]bool{}
+	r := snug.New()
+	r.Get("/hello", func(w http.ResponseWriter, r *http.Request) {
+		_, flushers[r.Method] = w.(http.Flusher)
+		w.Write([]byte("hello world"))
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	responses := map[string]*http.Response{}
+	for _, method := range []string{"GET", "HEAD"} {
+		req, err := http.NewRequest(method, srv.URL+"/hello", nil)
+		its.NoErr(err)
+		resp, err := http.DefaultClient.Do(req)
+		its.NoErr(err)
+		resp.Body.Close()
+		responses[method] = resp
+	}
+
+	get, head := responses["GET"], responses["HEAD"]
+	its.Equal(head.StatusCode, 200)
+	its.Equal(head.ContentLength, int64(11))
+	its.Equal(head.ContentLength, get.ContentLength)
+	head.Header.Del("Date")
+	get.Header.Del("Date")
+	its.Equal(head.Header, get.Header)
+	its.True(flushers["GET"])  // get handler not given a flusher
+	its.True(flushers["HEAD"]) // head handler not given a flusher
+}
+
+func TestCaseSensitivity(t *testing.T) {
+	its := is.New(t)
+
+	f := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(snug.Param(r, "fileName")))
+	}
+
+	testcases := []struct {
+		name          string
+		caseSensitive bool
+		path          string
+		status        int
+		expected      string
+	}{
+		{"insensitive exact", false, "/Files/ReadMe.MD", 200, "ReadMe.MD"},
+		{"insensitive folded", false, "/files/ReadMe.MD", 200, "ReadMe.MD"},
+		{"sensitive exact", true, "/Files/ReadMe.MD", 200, "ReadMe.MD"},
+		{"sensitive folded", true, "/files/ReadMe.MD", 404, `{"error":"not found"}`},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := snug.New()
+			r.CaseSensitive = tc.caseSensitive
+			r.Get("/Files/<fileName>", f)
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest("GET", tc.path, nil))
+			its.Equal(rec.Code, tc.status)
+			its.Equal(rec.Body.String(), tc.expected) // param name or value case changed
+		})
+	}
+}
+
+func TestURL(t *testing.T) {
+	its := is.New(t)
+
+	f := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(snug.Param(r, "tag") + snug.Param(r, "path")))
+	}
+
+	r := snug.New()
+	r.Prefix = "/api"
+	r.Get("/items/<id:int>", f).Name("item.detail")
+	r.Get("/items/<id:int>/tags/<tag>", f).Name("item.tag")
+	r.Get("/", f).Name("index")
+	r.Group("/files", func(g *snug.Router) {
+		g.Get("/*path", f).Name("file")
+		g.Get("/browse/*", f).Name("browse")
+	})
+
+	testcases := []struct {
+		name     string
+		route    string
+		pairs    []string
+		expected string
+		err      string
+	}{
+		{"substitute param", "item.detail", []string{"id", "42"}, "/api/items/42", ""},
+		{"escape value", "item.tag", []string{"id", "1", "tag", "a b?c"}, "/api/items/1/tags/a%20b%3Fc", ""},
+		{"root", "index", nil, "/api", ""},
+		{"named wildcard", "file", []string{"path", "docs/read me.md"}, "/api/files/docs/read%20me.md", ""},
+		{"unnamed wildcard", "browse", nil, "/api/files/browse", ""},
+		{"unknown route", "none", nil, "", "unknown route: none"},
+		{"missing param", "item.tag", []string{"id", "1"}, "", "route item.tag: missing url parameter: tag"},
+		{"extra param", "item.detail", []string{"id", "1", "x", "2"}, "", "route item.detail: unknown url parameter: x"},
+		{"duplicate param", "item.detail", []string{"id", "1", "id", "2"}, "", "route item.detail: duplicate url parameter: id"},
+		{"odd pairs", "item.detail", []string{"id"}, "", "route item.detail: odd number of url parameter pairs"},
+		{"constraint violated", "item.detail", []string{"id", "abc"}, "", `route item.detail: url parameter id: "abc" does not match int`},
+		{"empty value", "item.tag", []string{"id", "1", "tag", ""}, "", "route item.tag: url parameter tag: empty value"},
+		{"slash in value", "item.tag", []string{"id", "1", "tag", "a/b"}, "", `route item.tag: url parameter tag: "a/b" contains a slash`},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			path, err := r.URL(tc.route, tc.pairs...)
+			if tc.err != "" {
+				its.Equal(err.Error(), tc.err)
+				return
+			}
+			its.NoErr(err)
+			its.Equal(path, tc.expected)
+		})
+	}
+
+	t.Run("built url is routed back", func(t *testing.T) {
+		roundtrip := []struct {
+			route string
+			pairs []string
+			param string
+		}{
+			{"item.tag", []string{"id", "7", "tag", "a b?c%d"}, "tag"},
+			{"file", []string{"path", "docs/read me?.md"}, "path"},
+		}
+		for _, tc := range roundtrip {
+			path, err := r.URL(tc.route, tc.pairs...)
+			its.NoErr(err)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+			its.Equal(rec.Code, 200)
+			its.Equal(rec.Body.String(), tc.pairs[len(tc.pairs)-1]) // parameter value changed on the way
+		}
+	})
+
+	t.Run("duplicate name panics", func(t *testing.T) {
+		defer func() {
+			its.Equal(recover(), "duplicate route name: index")
+		}()
+		r.Post("/other", f).Name("index")
+	})
+}
+
+func TestRoutes(t *testing.T) {
+	its := is.New(t)
+
+	f := func(w http.ResponseWriter, r *http.Request) {}
+	mw := func(next http.HandlerFunc) http.HandlerFunc { return next }
+
+	r := snug.New()
+	r.Get("/", f).Name("index")
+	r.UseMiddleware(mw)
+	r.Group("/items", func(g *snug.Router) {
+		g.UseMiddleware(mw)
+		g.Put("/<id:int>/tags/<tag>", f)
+	})
+	r.Mount("/files", http.NotFoundHandler())
+
+	expected := []snug.RouteInfo{
+		{Method: "GET", Pattern: "/", Name: "index", Params: []string{}, Middleware: 0},
+		{Method: "PUT", Pattern: "/items/<id:int>/tags/<tag>", Params: []string{"id", "tag"}, Middleware: 2},
+		{Method: "*", Pattern: "/files/*", Params: []string{}, Middleware: 1},
+	}
+	its.Equal(r.Routes(), expected)
+
+	t.Run("walk stops on error", func(t *testing.T) {
+		visited := 0
+		stop := fmt.Errorf("stop")
+		err := r.Walk(func(ri snug.RouteInfo) error {
+			visited++
+			if ri.Method == "PUT" {
+				return stop
+			}
+			return nil
+		})
+		its.Equal(err, stop)
+		its.Equal(visited, 2)
+	})
+}
+
+func TestPattern(t *testing.T) {
+	its := is.New(t)
+
+	pattern := "unset"
+	mw := func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			next(w, r)
+			pattern = snug.Pattern(r)
+		}
+	}
+
+	r := snug.New()
+	r.Prefix = "/api"
+	r.UseMiddleware(mw)
+	r.Get("/greet/age/<name>", func(w http.ResponseWriter, r *http.Request) {})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/greet/age/esa", nil))
+	its.Equal(pattern, "/api/greet/age/<name>")
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/none", nil))
+	its.Equal(pattern, "") // fallback has no pattern
+
+	its.Equal(snug.Pattern(httptest.NewRequest("GET", "/", nil)), "")
+}
+
 func TestDuplicateRoutePanics(t *testing.T) {
 
 	its := is.New(t)
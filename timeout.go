@@ -0,0 +1,175 @@
+package snug
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Timeout returns a middleware cancelling request context after d.
+//
+// If handler has not started writing the response when d elapses, client gets
+// status 503 and body:
+//
+//	{"error": "timeout"}
+//
+// Headers set by handler and middleware inside Timeout are not sent with it,
+// apart from X-Request-ID of AssignRequestID.
+//
+// Handler runs in its own goroutine and should stop when its context is done.
+// Writes after the timeout are discarded and return http.ErrHandlerTimeout.
+// A panic in handler is propagated to the goroutine serving the request, so
+// Recover wrapping Timeout still catches it, with the stack of the handler.
+//
+//	r.UseMiddleware(snug.Timeout(5 * time.Second))
+func Timeout(d time.Duration) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			r = r.WithContext(ctx)
+
+			tw := &timeoutWriter{w: w, header: w.Header().Clone(), ctx: ctx}
+			done := make(chan struct{})
+			panicked := make(chan any, 1)
+			go func() {
+				defer func() {
+					if v := recover(); v != nil {
+						if _, ok := v.(*handlerPanic); !ok && v != http.ErrAbortHandler {
+							v = &handlerPanic{v, debug.Stack()}
+						}
+						panicked <- v
+					}
+				}()
+				next(tw, r)
+				close(done)
+			}()
+
+			select {
+			case v := <-panicked:
+				tw.stop()
+				panic(v)
+			case <-done:
+				tw.mu.Lock()
+				timedOut := tw.timedOut()
+				if !timedOut {
+					// handler may have set headers without writing anything
+					tw.start()
+				}
+				tw.mu.Unlock()
+				if timedOut {
+					writeTimeout(w, r)
+				}
+			case <-ctx.Done():
+				if !tw.stop() && ctx.Err() == context.DeadlineExceeded {
+					writeTimeout(w, r)
+				}
+			}
+		}
+	}
+}
+
+// Write the timeout response. Headers set by handler are discarded with the
+// rest of its response, except request ID assigned inside Timeout.
+func writeTimeout(w http.ResponseWriter, r *http.Request) {
+	if id := RequestID(r); id != "" {
+		w.Header().Set(RequestIDHeader, id)
+	}
+	writeError(w, r, http.StatusServiceUnavailable, "timeout")
+}
+
+// handlerPanic carries a panic of a handler run by Timeout to the goroutine
+// serving the request, with the stack where it happened.
+type handlerPanic struct {
+	value any
+	stack []byte
+}
+
+// Error formats panic like net/http logs it, when it is not recovered.
+func (p *handlerPanic) Error() string {
+	return fmt.Sprintf("%v\n\n%s", p.value, p.stack)
+}
+
+// timeoutWriter passes writes to w until stopped. Handler modifies its own
+// header map, which is copied to w when response starts, so that timeout
+// response does not race with handler.
+type timeoutWriter struct {
+	w      http.ResponseWriter
+	header http.Header
+	ctx    context.Context
+
+	mu      sync.Mutex
+	started bool
+	stopped bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut() {
+		return
+	}
+	tw.start()
+	tw.w.WriteHeader(status)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut() {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.start()
+	return tw.w.Write(b)
+}
+
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if f, ok := tw.w.(http.Flusher); ok && !tw.timedOut() {
+		tw.start()
+		f.Flush()
+	}
+}
+
+// Report whether writes must be discarded: writer was stopped, or deadline
+// passed before response started and timeout response is on its way. Called
+// with mu held.
+func (tw *timeoutWriter) timedOut() bool {
+	if !tw.started && tw.ctx.Err() == context.DeadlineExceeded {
+		tw.stopped = true
+	}
+	return tw.stopped
+}
+
+// Copy handler headers to w on first write. Called with mu held.
+func (tw *timeoutWriter) start() {
+	if tw.started {
+		return
+	}
+	tw.started = true
+	dst := tw.w.Header()
+	for k := range dst {
+		if _, ok := tw.header[k]; !ok {
+			delete(dst, k)
+		}
+	}
+	for k, v := range tw.header {
+		dst[k] = v
+	}
+}
+
+// Discard further writes. Reports whether handler had started the response.
+func (tw *timeoutWriter) stop() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.stopped = true
+	return tw.started
+}
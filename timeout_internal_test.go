@@ -0,0 +1,36 @@
+package snug
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+// writes after the deadline are discarded unless response already started
+func TestTimeoutWriter(t *testing.T) {
+	its := is.New(t)
+
+	expired, cancel := context.WithDeadline(context.Background(), time.Unix(0, 0))
+	defer cancel()
+
+	rec := httptest.NewRecorder()
+	tw := &timeoutWriter{w: rec, header: http.Header{}, ctx: expired}
+	_, err := tw.Write([]byte("late"))
+	its.Equal(err, http.ErrHandlerTimeout) // late write not discarded
+	tw.WriteHeader(http.StatusCreated)
+	its.Equal(rec.Body.Len(), 0)
+	its.Equal(rec.Code, 200) // late WriteHeader passed through
+	its.True(!tw.stop())     // late write started response
+
+	rec = httptest.NewRecorder()
+	tw = &timeoutWriter{w: rec, header: http.Header{}, ctx: context.Background()}
+	tw.Write([]byte("a"))
+	tw.ctx = expired
+	_, err = tw.Write([]byte("b"))
+	its.NoErr(err) // write of started response discarded after deadline
+	its.Equal(rec.Body.String(), "ab")
+}
@@ -0,0 +1,127 @@
+package snug_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/samharju/snug"
+)
+
+func TestTimeout(t *testing.T) {
+	its := is.New(t)
+
+	lateErr := make(chan error, 1)
+
+	r := snug.New()
+	r.UseMiddleware(snug.Timeout(20 * time.Millisecond))
+	r.Get("/fast", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Handler", "fast")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("done"))
+	})
+	r.Get("/header-only", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Handler", "header-only")
+	})
+	r.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		w.Header().Set("X-Handler", "slow")
+		_, err := w.Write([]byte("late"))
+		lateErr <- err
+	})
+	r.Get("/started", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		<-r.Context().Done()
+	})
+
+	testcases := []struct {
+		path   string
+		status int
+		body   string
+		header string
+	}{
+		{"/fast", 201, "done", "fast"},
+		{"/header-only", 200, "", "header-only"},
+		{"/slow", 503, `{"error":"timeout"}`, ""},
+		{"/started", 200, "partial", ""},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest("GET", tc.path, nil))
+			its.Equal(rec.Code, tc.status)
+			its.Equal(rec.Body.String(), tc.body)
+			its.Equal(rec.Header().Get("X-Handler"), tc.header)
+		})
+	}
+
+	its.Equal(<-lateErr, http.ErrHandlerTimeout) // late write was not discarded
+}
+
+func TestTimeoutPanic(t *testing.T) {
+	its := is.New(t)
+
+	r := snug.New()
+	r.LogRoutes = false
+	r.Logger = snug.Discard
+	r.UseMiddleware(snug.Timeout(time.Second))
+	r.UseMiddleware(snug.Recover)
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		panic("in goroutine")
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	its.Equal(rec.Code, http.StatusInternalServerError)
+	its.Equal(rec.Body.String(), `{"error":"internal server error"}`)
+
+	var value any
+	var stack []byte
+	r = snug.New()
+	r.LogRoutes = false
+	r.UseMiddleware(snug.Timeout(time.Second))
+	r.UseMiddleware(snug.RecoverWith(func(w http.ResponseWriter, r *http.Request, v any, s []byte) {
+		value, stack = v, s
+	}))
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		panic("in goroutine")
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	its.Equal(value, "in goroutine")
+	its.True(strings.Contains(string(stack), "TestTimeoutPanic.func")) // stack is not of panicking handler
+}
+
+// handler returning or writing right after the deadline must not beat the
+// timeout response, whichever the middleware notices first
+func TestTimeoutLateHandler(t *testing.T) {
+	its := is.New(t)
+
+	lateErr := make(chan error, 1)
+
+	r := snug.New()
+	r.LogRoutes = false
+	r.UseMiddleware(snug.Timeout(time.Millisecond))
+	r.Get("/return", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	r.Get("/write", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		w.WriteHeader(http.StatusCreated)
+		_, err := w.Write([]byte("late"))
+		lateErr <- err
+	})
+
+	for i := 0; i < 50; i++ {
+		for _, path := range []string{"/return", "/write"} {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+			its.Equal(rec.Code, http.StatusServiceUnavailable)
+			its.Equal(rec.Body.String(), `{"error":"timeout"}`)
+		}
+		its.Equal(<-lateErr, http.ErrHandlerTimeout) // late write was not discarded
+	}
+}
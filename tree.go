@@ -0,0 +1,231 @@
+package snug
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// node is a vertex in the routing tree.
+//
+// Static segments are compressed: a chain of static segments with no branching
+// is held by a single node. Url parameters and the trailing wildcard always get
+// a node of their own.
+//
+// Lookup precedence is deterministic regardless of registration order:
+// static > constrained parameter > parameter > wildcard. Constrained
+// parameters are tried in registration order.
+type node struct {
+	// static segments matched by this node, empty for root, parameter and wildcard nodes
+	segs []string
+	// static children keyed by their first segment
+	static map[string]*node
+	// constraint of a parameter node, nil accepts any value
+	constraint *constraint
+	// children matching a single segment, unconstrained child is kept last
+	params []*node
+	// child matching the rest of the path, including nothing
+	wildcard *node
+	// registered endpoints keyed by method
+	endpoints map[string]*Route
+}
+
+func isParam(seg string) bool {
+	return strings.HasPrefix(seg, "<")
+}
+
+func isWildcard(seg string) bool {
+	return strings.HasPrefix(seg, "*")
+}
+
+// Split parameter segment <name:constraint> to name and constraint.
+func parseParam(seg string) (name, expr string) {
+	name, expr, _ = strings.Cut(strings.Trim(seg, "<>"), ":")
+	return name, expr
+}
+
+// Insert pattern segments below n and return the node terminating the pattern.
+func (n *node) insert(p []string) *node {
+	if len(p) == 0 {
+		return n
+	}
+
+	if isWildcard(p[0]) {
+		if n.wildcard == nil {
+			n.wildcard = &node{}
+		}
+		return n.wildcard
+	}
+
+	if isParam(p[0]) {
+		_, expr := parseParam(p[0])
+		for _, child := range n.params {
+			if child.constraint.source() == expr {
+				return child.insert(p[1:])
+			}
+		}
+		// expression is validated by parsePattern
+		c, _ := newConstraint(expr)
+		child := &node{constraint: c}
+		if child.constraint != nil {
+			// keep unconstrained parameter last
+			i := len(n.params)
+			if i > 0 && n.params[i-1].constraint == nil {
+				i--
+			}
+			n.params = append(n.params[:i], append([]*node{child}, n.params[i:]...)...)
+		} else {
+			n.params = append(n.params, child)
+		}
+		return child.insert(p[1:])
+	}
+
+	// run of static segments until next parameter or wildcard
+	k := 1
+	for k < len(p) && !isParam(p[k]) && !isWildcard(p[k]) {
+		k++
+	}
+
+	if n.static == nil {
+		n.static = map[string]*node{}
+	}
+	child, ok := n.static[p[0]]
+	if !ok {
+		child = &node{segs: p[:k]}
+		n.static[p[0]] = child
+		return child.insert(p[k:])
+	}
+
+	i := 1
+	for i < k && i < len(child.segs) && child.segs[i] == p[i] {
+		i++
+	}
+	if i < len(child.segs) {
+		// branch in the middle of a compressed chain, move the common
+		// segments to a new parent
+		parent := &node{
+			segs:   child.segs[:i],
+			static: map[string]*node{child.segs[i]: child},
+		}
+		child.segs = child.segs[i:]
+		n.static[p[0]] = parent
+		child = parent
+	}
+	return child.insert(p[i:])
+}
+
+// search holds the state of a single lookup through the tree.
+type search struct {
+	method string
+	// path segments used for matching, lowercased unless router is case sensitive
+	path []string
+	// original path segments used for parameter values
+	raw []string
+	// captured parameter values
+	values []string
+	// index of the first path segment matched by wildcard
+	rest int
+	// resolved endpoint
+	endpoint *Route
+	// nodes matching the path but not the method
+	matched []*node
+}
+
+// Accept endpoint of n if it is registered for searched method. HEAD falls
+// back to GET.
+func (s *search) accept(n *node) bool {
+	if len(n.endpoints) == 0 {
+		return false
+	}
+	e, ok := n.endpoints[s.method]
+	if !ok {
+		e, ok = n.endpoints["*"]
+	}
+	if !ok && s.method == http.MethodHead {
+		e, ok = n.endpoints[http.MethodGet]
+	}
+	if !ok {
+		s.matched = append(s.matched, n)
+		return false
+	}
+	s.endpoint = e
+	return true
+}
+
+// Return sorted methods allowed for the searched path, collected from all
+// patterns matching it.
+func (s *search) allow() []string {
+	return methodsOf(s.matched)
+}
+
+// Return sorted methods registered to nodes. HEAD is allowed with GET and
+// OPTIONS is always allowed.
+func methodsOf(nodes []*node) []string {
+	allowed := map[string]bool{http.MethodOptions: true}
+	for _, n := range nodes {
+		for method := range n.endpoints {
+			allowed[method] = true
+			if method == http.MethodGet {
+				allowed[http.MethodHead] = true
+			}
+		}
+	}
+	methods := make([]string, 0, len(allowed))
+	for method := range allowed {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// Accept endpoint of wildcard node n matching path segments from index i.
+func (s *search) acceptRest(n *node, i int) bool {
+	if !s.accept(n) {
+		return false
+	}
+	s.rest = i
+	return true
+}
+
+// Find endpoint for path segments starting from index i. Reports whether an
+// endpoint was accepted.
+func (n *node) find(s *search, i int) bool {
+	if i == len(s.path) {
+		if s.accept(n) {
+			return true
+		}
+		return n.wildcard != nil && s.acceptRest(n.wildcard, i)
+	}
+
+	if child, ok := n.static[s.path[i]]; ok && child.prefixOf(s.path[i:]) {
+		if child.find(s, i+len(child.segs)) {
+			return true
+		}
+	}
+
+	for _, child := range n.params {
+		if !child.constraint.match(s.raw[i]) {
+			continue
+		}
+		s.values = append(s.values, s.raw[i])
+		if child.find(s, i+1) {
+			return true
+		}
+		s.values = s.values[:len(s.values)-1]
+	}
+
+	return n.wildcard != nil && s.acceptRest(n.wildcard, i)
+}
+
+// Report whether static segments of n are a prefix of path.
+func (n *node) prefixOf(path []string) bool {
+	if len(n.segs) > len(path) {
+		return false
+	}
+	for i := range n.segs {
+		if n.segs[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
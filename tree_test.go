@@ -0,0 +1,178 @@
+package snug
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+// precedence must not depend on registration order
+func TestTreePrecedence(t *testing.T) {
+	its := is.New(t)
+
+	patterns := []string{"/api/*", "/api/<id>", "/api/items", "/api/<id>/detail", "/api/items/detail"}
+	orders := [][]int{{0, 1, 2, 3, 4}, {4, 3, 2, 1, 0}, {1, 0, 4, 2, 3}}
+
+	testcases := []struct {
+		path     string
+		expected string
+	}{
+		{"/api/items", "/api/items"},
+		{"/api/123", "/api/<id>"},
+		{"/api/123/detail", "/api/<id>/detail"},
+		{"/api/items/detail", "/api/items/detail"},
+		{"/api/items/other", "/api/*"},
+		{"/api", "/api/*"},
+		{"/api/a/b/c", "/api/*"},
+	}
+
+	for _, order := range orders {
+		r := New()
+		for _, i := range order {
+			p := patterns[i]
+			r.HandleFunc("GET", p, func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(p))
+			})
+		}
+		for _, tc := range testcases {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest("GET", tc.path, nil))
+			its.Equal(rec.Body.String(), tc.expected) // wrong route won
+		}
+	}
+}
+
+// static chains are compressed and split when a branch appears
+func TestTreeSplit(t *testing.T) {
+	its := is.New(t)
+
+	root := &node{}
+	a := root.insert([]string{"a", "b", "c"})
+	its.Equal(len(root.static), 1)
+	its.Equal(root.static["a"].segs, []string{"a", "b", "c"})
+
+	b := root.insert([]string{"a", "b", "d"})
+	ab := root.static["a"]
+	its.Equal(ab.segs, []string{"a", "b"})
+	its.Equal(len(ab.static), 2)
+	its.True(ab.static["c"] == a) // split must keep existing node identity
+	its.True(ab.static["d"] == b)
+
+	its.True(root.insert([]string{"a", "b"}) == ab)
+}
+
+// backtracking from a static branch to a parameter branch
+func TestTreeBacktrack(t *testing.T) {
+	its := is.New(t)
+
+	r := New()
+	r.HandleFunc("GET", "/a/b/c", func(w http.ResponseWriter, r *http.Request) {})
+	r.HandleFunc("GET", "/a/<x>/d", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(Param(r, "x")))
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/a/B/d", nil))
+	its.Equal(rec.Code, 200)
+	its.Equal(rec.Body.String(), "B")
+}
+
+func TestMalformedWildcard(t *testing.T) {
+	its := is.New(t)
+
+	defer func() {
+		its.Equal(recover(), "malformed pattern: /api/*/x")
+	}()
+	New().HandleFunc("GET", "/api/*/x", func(w http.ResponseWriter, r *http.Request) {})
+}
+
+// linearEndpoint is the previous slice based route matching, kept here as a
+// baseline for benchmarks.
+type linearEndpoint struct {
+	method   string
+	path     []string
+	handler  http.HandlerFunc
+	wildcard bool
+}
+
+func (e linearEndpoint) match(method string, path []string) (hf http.HandlerFunc, notallowed bool) {
+	if (len(path) != len(e.path)) && !e.wildcard {
+		return
+	}
+	for i, p := range e.path {
+		if p == "*" {
+			break
+		}
+		if path[i] != p && !strings.HasPrefix(p, "<") {
+			return
+		}
+	}
+	if (e.method != "*") && (method != e.method) {
+		return nil, true
+	}
+	return e.handler, false
+}
+
+// Generate a route table resembling a real api with n resources.
+func benchPatterns(n int) []string {
+	patterns := []string{}
+	for i := 0; i < n; i++ {
+		patterns = append(patterns,
+			fmt.Sprintf("/api/v1/resource%d", i),
+			fmt.Sprintf("/api/v1/resource%d/<id>", i),
+			fmt.Sprintf("/api/v1/resource%d/<id>/status", i),
+			fmt.Sprintf("/api/v1/resource%d/<id>/items/<item>", i),
+		)
+	}
+	return patterns
+}
+
+func benchmarkTree(b *testing.B, n int) {
+	root := &node{}
+	for _, p := range benchPatterns(n) {
+		segs, params, _ := parsePattern(p, false)
+		leaf := root.insert(segs)
+		leaf.endpoints = map[string]*Route{"GET": {method: "GET", pattern: p, params: params}}
+	}
+	path := strings.Split(fmt.Sprintf("api/v1/resource%d/42/items/7", n-1), "/")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s := search{method: "GET", path: path, raw: path}
+		if !root.find(&s, 0) {
+			b.Fatal("no match")
+		}
+	}
+}
+
+func benchmarkLinear(b *testing.B, n int) {
+	routes := []linearEndpoint{}
+	f := func(w http.ResponseWriter, r *http.Request) {}
+	for _, p := range benchPatterns(n) {
+		segs, _, _ := parsePattern(p, false)
+		routes = append(routes, linearEndpoint{method: "GET", path: segs, handler: f})
+	}
+	path := strings.Split(fmt.Sprintf("api/v1/resource%d/42/items/7", n-1), "/")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var hf http.HandlerFunc
+		for _, e := range routes {
+			if hf, _ = e.match("GET", path); hf != nil {
+				break
+			}
+		}
+		if hf == nil {
+			b.Fatal("no match")
+		}
+	}
+}
+
+func BenchmarkTree10(b *testing.B)    { benchmarkTree(b, 10) }
+func BenchmarkTree100(b *testing.B)   { benchmarkTree(b, 100) }
+func BenchmarkLinear10(b *testing.B)  { benchmarkLinear(b, 10) }
+func BenchmarkLinear100(b *testing.B) { benchmarkLinear(b, 100) }
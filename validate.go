@@ -0,0 +1,270 @@
+package snug
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// rule is a single validation rule parsed from a snug tag, like min=3.
+type rule struct {
+	name  string
+	param string
+	// set by compile for regex and oneof
+	re      *regexp.Regexp
+	options []string
+}
+
+// Split snug tag to rules. Parameter of regex may contain commas, so it
+// consumes the rest of the tag.
+func parseRules(tag string) []rule {
+	rules := []rule{}
+	for tag != "" {
+		var r string
+		if strings.HasPrefix(tag, "regex=") {
+			r, tag = tag, ""
+		} else {
+			r, tag, _ = strings.Cut(tag, ",")
+		}
+		name, param, _ := strings.Cut(strings.TrimSpace(r), "=")
+		if name != "" {
+			rules = append(rules, rule{name: name, param: param})
+		}
+	}
+	return rules
+}
+
+// Prepare rule for checking values of type t. Returns an error if rule is
+// unknown, has an invalid parameter or does not apply to type t.
+func (ru rule) compile(t reflect.Type) (rule, error) {
+	if ru.name == "required" {
+		return ru, nil
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	k := t.Kind()
+	var err error
+	switch ru.name {
+	case "notempty":
+	case "min", "max", "len", "gt", "lt":
+		switch {
+		case hasLen(k):
+			_, err = strconv.Atoi(ru.param)
+		case ru.name == "len":
+			return ru, ru.invalid(t)
+		case isIntKind(k):
+			_, err = strconv.ParseInt(ru.param, 10, 64)
+		case isUintKind(k):
+			_, err = strconv.ParseUint(ru.param, 10, 64)
+		case isFloatKind(k):
+			_, err = strconv.ParseFloat(ru.param, 64)
+		default:
+			return ru, ru.invalid(t)
+		}
+	case "oneof":
+		if k != reflect.String && k != reflect.Bool && !isIntKind(k) && !isUintKind(k) && !isFloatKind(k) {
+			return ru, ru.invalid(t)
+		}
+		ru.options = strings.Split(ru.param, "|")
+	case "email", "url", "uuid":
+		if k != reflect.String {
+			return ru, ru.invalid(t)
+		}
+	case "regex":
+		if k != reflect.String {
+			return ru, ru.invalid(t)
+		}
+		ru.re, err = compileWhole(ru.param)
+		if err != nil {
+			return ru, fmt.Errorf("invalid rule: regex=%s: %w", ru.param, err)
+		}
+	default:
+		return ru, errors.New("unknown rule: " + ru.name)
+	}
+	if err != nil {
+		return ru, errors.New("invalid rule: " + ru.name + "=" + ru.param)
+	}
+	return ru, nil
+}
+
+// Return error for a rule that does not apply to type t.
+func (ru rule) invalid(t reflect.Type) error {
+	return fmt.Errorf("invalid rule: %s does not apply to %s", ru.name, t)
+}
+
+// Validate v against rule compiled for type of v. Returns a message
+// describing the violation, empty if v is valid. Rules other than required
+// skip nil pointers, so optional fields are validated only when present.
+func (ru rule) check(v reflect.Value) string {
+	if ru.name == "required" {
+		if v.IsZero() {
+			return "is a required field"
+		}
+		return ""
+	}
+
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	switch ru.name {
+	case "notempty":
+		if hasLen(v.Kind()) && v.Len() == 0 || !hasLen(v.Kind()) && v.IsZero() {
+			return "must not be empty"
+		}
+	case "min":
+		if ru.compare(v) < 0 {
+			return ru.describe(v, "must be at least %s", "must contain at least %s %s")
+		}
+	case "max":
+		if ru.compare(v) > 0 {
+			return ru.describe(v, "must be at most %s", "must contain at most %s %s")
+		}
+	case "len":
+		if ru.compare(v) != 0 {
+			return ru.describe(v, "", "must contain exactly %s %s")
+		}
+	case "gt":
+		if ru.compare(v) <= 0 {
+			return ru.describe(v, "must be greater than %s", "must contain more than %s %s")
+		}
+	case "lt":
+		if ru.compare(v) >= 0 {
+			return ru.describe(v, "must be less than %s", "must contain fewer than %s %s")
+		}
+	case "oneof":
+		value := formatScalar(v)
+		for _, o := range ru.options {
+			if value == o {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(ru.options, ", ")
+	case "email":
+		if !isEmail(v.String()) {
+			return "must be a valid email address"
+		}
+	case "url":
+		if !isURL(v.String()) {
+			return "must be a valid url"
+		}
+	case "uuid":
+		if !isUUID(v.String()) {
+			return "must be a valid uuid"
+		}
+	case "regex":
+		if !ru.re.MatchString(v.String()) {
+			return "must match " + ru.param
+		}
+	}
+	return ""
+}
+
+// Compare v to parameter of rule, length for strings, slices and maps. Length
+// of a string is counted in characters. Returns -1, 0 or 1. Parameter is
+// checked by compile.
+func (ru rule) compare(v reflect.Value) int {
+	switch {
+	case v.Kind() == reflect.String:
+		n, _ := strconv.Atoi(ru.param)
+		return cmp(utf8.RuneCountInString(v.String()), n)
+	case hasLen(v.Kind()):
+		n, _ := strconv.Atoi(ru.param)
+		return cmp(v.Len(), n)
+	case v.CanInt():
+		n, _ := strconv.ParseInt(ru.param, 10, 64)
+		return cmp(v.Int(), n)
+	case v.CanUint():
+		n, _ := strconv.ParseUint(ru.param, 10, 64)
+		return cmp(v.Uint(), n)
+	}
+	n, _ := strconv.ParseFloat(ru.param, 64)
+	return cmp(v.Float(), n)
+}
+
+// Pick message for a numeric value or for a value with length.
+func (ru rule) describe(v reflect.Value, number, length string) string {
+	if !hasLen(v.Kind()) {
+		return fmt.Sprintf(number, ru.param)
+	}
+	unit := "item"
+	if v.Kind() == reflect.String {
+		unit = "character"
+	}
+	if ru.param != "1" {
+		unit += "s"
+	}
+	return fmt.Sprintf(length, ru.param, unit)
+}
+
+// Format string, bool or number v for comparing to options of oneof.
+func formatScalar(v reflect.Value) string {
+	switch {
+	case v.Kind() == reflect.String:
+		return v.String()
+	case v.Kind() == reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case v.CanInt():
+		return strconv.FormatInt(v.Int(), 10)
+	case v.CanUint():
+		return strconv.FormatUint(v.Uint(), 10)
+	}
+	return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits())
+}
+
+func hasLen(k reflect.Kind) bool {
+	switch k {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return true
+	}
+	return false
+}
+
+func isIntKind(k reflect.Kind) bool {
+	return reflect.Int <= k && k <= reflect.Int64
+}
+
+func isUintKind(k reflect.Kind) bool {
+	return reflect.Uint <= k && k <= reflect.Uintptr
+}
+
+func isFloatKind(k reflect.Kind) bool {
+	return k == reflect.Float32 || k == reflect.Float64
+}
+
+type ordered interface {
+	~int | ~int64 | ~uint64 | ~float64
+}
+
+func cmp[T ordered](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Report whether s is a bare email address, without a display name.
+func isEmail(s string) bool {
+	a, err := mail.ParseAddress(s)
+	return err == nil && a.Address == s
+}
+
+// Report whether s is an absolute url with a host.
+func isURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
@@ -0,0 +1,106 @@
+package snug
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestParseRules(t *testing.T) {
+	its := is.New(t)
+
+	testcases := []struct {
+		tag      string
+		expected []rule
+	}{
+		{"", []rule{}},
+		{"required", []rule{{name: "required"}}},
+		{"required, min=3,max=10", []rule{{name: "required"}, {name: "min", param: "3"}, {name: "max", param: "10"}}},
+		{"oneof=a|b,uuid", []rule{{name: "oneof", param: "a|b"}, {name: "uuid"}}},
+		{"min=1,regex=[a-z]{1,3},x", []rule{{name: "min", param: "1"}, {name: "regex", param: "[a-z]{1,3},x"}}},
+	}
+
+	for _, tc := range testcases {
+		its.Equal(parseRules(tc.tag), tc.expected) // rules parsed wrong
+	}
+}
+
+func TestRuleCheck(t *testing.T) {
+	its := is.New(t)
+
+	three := 3
+	var none *int
+
+	testcases := []struct {
+		rule     rule
+		value    any
+		expected string
+	}{
+		{rule{name: "required"}, 0, "is a required field"},
+		{rule{name: "required"}, none, "is a required field"},
+		{rule{name: "notempty"}, "", "must not be empty"},
+		{rule{name: "notempty"}, []int{}, "must not be empty"},
+		{rule{name: "notempty"}, map[string]int{"a": 1}, ""},
+		{rule{name: "notempty"}, 0.0, "must not be empty"},
+		{rule{name: "min", param: "3"}, "ab", "must contain at least 3 characters"},
+		{rule{name: "min", param: "3"}, "abc", ""},
+		{rule{name: "max", param: "5"}, "äöü", ""},
+		{rule{name: "max", param: "5"}, "äöüäöü", "must contain at most 5 characters"},
+		{rule{name: "len", param: "3"}, "äöü", ""},
+		{rule{name: "min", param: "3"}, 2, "must be at least 3"},
+		{rule{name: "min", param: "3"}, &three, ""},
+		{rule{name: "min", param: "3"}, none, ""},
+		{rule{name: "min", param: "-1.5"}, -2.0, "must be at least -1.5"},
+		{rule{name: "max", param: "2"}, []string{"a", "b", "c"}, "must contain at most 2 items"},
+		{rule{name: "max", param: "2"}, uint8(3), "must be at most 2"},
+		{rule{name: "len", param: "2"}, map[string]int{"a": 1}, "must contain exactly 2 items"},
+		{rule{name: "len", param: "2"}, "ab", ""},
+		{rule{name: "gt", param: "3"}, 3, "must be greater than 3"},
+		{rule{name: "gt", param: "3"}, 4, ""},
+		{rule{name: "lt", param: "3"}, 3, "must be less than 3"},
+		{rule{name: "lt", param: "2"}, "ab", "must contain fewer than 2 characters"},
+		{rule{name: "oneof", param: "red|green"}, "blue", "must be one of red, green"},
+		{rule{name: "oneof", param: "red|green"}, "green", ""},
+		{rule{name: "oneof", param: "1|2"}, 2, ""},
+		{rule{name: "email"}, "user@example.com", ""},
+		{rule{name: "email"}, "User <user@example.com>", "must be a valid email address"},
+		{rule{name: "email"}, "user", "must be a valid email address"},
+		{rule{name: "url"}, "https://example.com/a?b=c", ""},
+		{rule{name: "url"}, "/relative", "must be a valid url"},
+		{rule{name: "uuid"}, "123e4567-e89b-12d3-a456-426614174000", ""},
+		{rule{name: "uuid"}, "123", "must be a valid uuid"},
+		{rule{name: "regex", param: "[a-z]+"}, "abc", ""},
+		{rule{name: "regex", param: "[a-z]+"}, "abc1", "must match [a-z]+"},
+	}
+
+	for _, tc := range testcases {
+		v := reflect.ValueOf(tc.value)
+		ru, err := tc.rule.compile(v.Type())
+		its.NoErr(err)
+		its.Equal(ru.check(v), tc.expected) // check result != expected
+	}
+}
+
+func TestRuleCompile(t *testing.T) {
+	its := is.New(t)
+
+	testcases := []struct {
+		rule     rule
+		value    any
+		expected string
+	}{
+		{rule{name: "nope"}, "", "unknown rule: nope"},
+		{rule{name: "min", param: "x"}, 1, "invalid rule: min=x"},
+		{rule{name: "len", param: "1"}, 1, "invalid rule: len does not apply to int"},
+		{rule{name: "email"}, 1, "invalid rule: email does not apply to int"},
+		{rule{name: "oneof", param: "a"}, []string{}, "invalid rule: oneof does not apply to []string"},
+		{rule{name: "regex", param: "[a-z"}, "", "invalid rule: regex=[a-z: error parsing regexp: missing closing ]: `[a-z`"},
+	}
+
+	for _, tc := range testcases {
+		_, err := tc.rule.compile(reflect.TypeOf(tc.value))
+		its.True(err != nil)                // expected err but got nil
+		its.Equal(err.Error(), tc.expected) // wrong error
+	}
+}